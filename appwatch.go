@@ -0,0 +1,136 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// appWatcher notices when the app the sidecar runs next to is done, so that
+// the sidecar of a run-to-completion container, e.g. a Cloud Run job task,
+// can exit too. The app is done once it creates doneFile or, if no doneFile
+// is set, once it stops listening on all of its metrics ports after having
+// listened on any of them.
+type appWatcher struct {
+	doneFile     string
+	pollInterval time.Duration
+	dialTimeout  time.Duration
+
+	mu    sync.Mutex
+	ports []string
+	// Whether the app has been seen listening on ports. A port that was never
+	// opened does not mean the app is done, it may still be starting.
+	listened bool
+}
+
+func newAppWatcher(doneFile string, pollInterval time.Duration) *appWatcher {
+	return &appWatcher{
+		doneFile:     doneFile,
+		pollInterval: pollInterval,
+		dialTimeout:  time.Second,
+	}
+}
+
+// SetPorts sets the metrics ports of the app, which change with the config.
+// The app has to be seen listening on the new ports before it can be
+// considered done.
+func (w *appWatcher) SetPorts(ports []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ports = ports
+	w.listened = false
+}
+
+// Watch polls the app in the background until it is done or ctx is
+// cancelled. The returned channel receives why the app is considered done.
+func (w *appWatcher) Watch(ctx context.Context) <-chan string {
+	done := make(chan string, 1)
+	go func() {
+		ticker := time.NewTicker(w.pollInterval)
+		defer ticker.Stop()
+		for {
+			if reason, ok := w.check(ctx); ok {
+				done <- reason
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// check reports whether the app is done, and if so why.
+func (w *appWatcher) check(ctx context.Context) (string, bool) {
+	if w.doneFile != "" {
+		if _, err := os.Stat(w.doneFile); err == nil {
+			return fmt.Sprintf("app created %s", w.doneFile), true
+		}
+		return "", false
+	}
+
+	w.mu.Lock()
+	ports := w.ports
+	w.mu.Unlock()
+	if len(ports) == 0 {
+		return "", false
+	}
+	listening := false
+	for _, port := range ports {
+		if w.listening(ctx, port) {
+			listening = true
+			break
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Ignore the result if the ports changed in the meantime.
+	if !slices.Equal(ports, w.ports) {
+		return "", false
+	}
+	if listening {
+		w.listened = true
+		return "", false
+	}
+	if !w.listened {
+		return "", false
+	}
+	if len(ports) == 1 {
+		return fmt.Sprintf("app stopped listening on port %s", ports[0]), true
+	}
+	return fmt.Sprintf("app stopped listening on ports %s", strings.Join(ports, ", ")), true
+}
+
+// listening reports whether the app accepts connections on port.
+func (w *appWatcher) listening(ctx context.Context, port string) bool {
+	d := net.Dialer{Timeout: w.dialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort("localhost", port))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,104 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listenLocal(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	return l, strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestAppWatcherDoneFile(t *testing.T) {
+	doneFile := filepath.Join(t.TempDir(), "done")
+	w := newAppWatcher(doneFile, 10*time.Millisecond)
+	// Ports are ignored if the app signals with a file.
+	w.SetPorts([]string{"1"})
+	ctx := context.Background()
+
+	_, done := w.check(ctx)
+	assert.False(t, done)
+
+	require.NoError(t, os.WriteFile(doneFile, nil, 0644))
+	reason, done := w.check(ctx)
+	assert.True(t, done)
+	assert.Equal(t, "app created "+doneFile, reason)
+}
+
+func TestAppWatcherPorts(t *testing.T) {
+	ctx := context.Background()
+	l1, port1 := listenLocal(t)
+	l2, port2 := listenLocal(t)
+	w := newAppWatcher("", 10*time.Millisecond)
+
+	_, done := w.check(ctx)
+	assert.False(t, done, "no ports to watch")
+
+	w.SetPorts([]string{port1, port2})
+	_, done = w.check(ctx)
+	assert.False(t, done, "app is listening")
+
+	// The app is not done while it listens on any of its ports.
+	l1.Close()
+	_, done = w.check(ctx)
+	assert.False(t, done, "app is listening on one port")
+
+	l2.Close()
+	reason, done := w.check(ctx)
+	assert.True(t, done)
+	assert.Equal(t, "app stopped listening on ports "+port1+", "+port2, reason)
+
+	// The app has to be seen listening on new ports first.
+	w.SetPorts([]string{port1})
+	_, done = w.check(ctx)
+	assert.False(t, done, "app was never seen listening on the new port")
+}
+
+func TestAppWatcherWatch(t *testing.T) {
+	l, port := listenLocal(t)
+	w := newAppWatcher("", 10*time.Millisecond)
+	w.SetPorts([]string{port})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := w.Watch(ctx)
+
+	select {
+	case reason := <-done:
+		t.Fatalf("app is done while listening: %s", reason)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	l.Close()
+	select {
+	case reason := <-done:
+		assert.Equal(t, "app stopped listening on port "+port, reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("app is not done after it stopped listening")
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// otelCollector runs the OTel collector with the configs generated by the
+// entrypoint, either as a subprocess or inside the entrypoint process.
+type otelCollector interface {
+	// Configure hands a generated OTel config to the collector. Before Start
+	// it sets the config the collector starts with, afterwards it makes the
+	// running collector reload.
+	Configure(otel string) error
+	// Start starts the collector.
+	Start() error
+	// Healthy reports whether the collector is not crash looping.
+	Healthy() bool
+	// Running reports whether the collector is currently running.
+	Running() bool
+	// Signal sends sig to the collector process. It is a no-op for a
+	// collector that runs in the entrypoint process.
+	Signal(sig os.Signal) error
+	// Process returns the PID of the process running the collector and when
+	// the collector was started. The PID is 0 if the collector is not running.
+	Process() (pid int, startedAt time.Time)
+	// Stop asks the collector to shut down with sig and waits at most timeout
+	// for it. It returns the final state of the collector process, which is
+	// nil unless the collector ran in a process of its own.
+	Stop(sig os.Signal, timeout time.Duration) *os.ProcessState
+}
+
+// subprocessCollector runs the collector binary as a supervised subprocess,
+// and hands it its config through a file.
+type subprocessCollector struct {
+	*collectorSupervisor
+	configFile string
+}
+
+func newSubprocessCollector(binary, configFile string, flags ...string) *subprocessCollector {
+	return &subprocessCollector{
+		collectorSupervisor: newCollectorSupervisor(append([]string{binary, "--config", configFile}, flags...)...),
+		configFile:          configFile,
+	}
+}
+
+// Configure writes the config file and signals the collector to reload it.
+// The config file is left untouched if it can't be written.
+func (c *subprocessCollector) Configure(otel string) error {
+	if err := os.MkdirAll(filepath.Dir(c.configFile), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %q: %v", c.configFile, err)
+	}
+	if err := writeFileAtomic(c.configFile, []byte(otel), 0644); err != nil {
+		return fmt.Errorf("failed to write file to %q: %v", c.configFile, err)
+	}
+	if err := c.Signal(syscall.SIGHUP); err != nil {
+		return fmt.Errorf("failed to signal the collector to reload: %v", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// over path, so that readers only ever see the old or the new content.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Clean up the temporary file on failure. This is a no-op after the rename.
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
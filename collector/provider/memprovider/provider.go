@@ -0,0 +1,105 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memprovider implements a confmap provider that serves a config
+// held in memory. It lets the entrypoint hand the generated config to a
+// collector running in the same process, and have it reloaded on changes,
+// without going through a file.
+package memprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/collector/confmap"
+)
+
+const scheme = "mem"
+
+// Provider serves the config last passed to Set under URI.
+type Provider struct {
+	mu      sync.Mutex
+	config  []byte
+	watcher confmap.WatcherFunc
+	// Number of retrievals so far, to tell whether watcher belongs to the
+	// retrieval being closed.
+	retrievals int
+}
+
+func New() *Provider {
+	return &Provider{}
+}
+
+// URI returns the URI the collector retrieves the config from.
+func (p *Provider) URI() string {
+	return scheme + ":config"
+}
+
+// NewFactory returns a factory that always returns p.
+func (p *Provider) NewFactory() confmap.ProviderFactory {
+	return confmap.NewProviderFactory(func(confmap.ProviderSettings) confmap.Provider {
+		return p
+	})
+}
+
+// Set replaces the config, and asks the collector to reload it if it has
+// retrieved the previous one.
+func (p *Provider) Set(config []byte) {
+	p.mu.Lock()
+	p.config = config
+	watcher := p.watcher
+	p.mu.Unlock()
+
+	if watcher != nil {
+		watcher(&confmap.ChangeEvent{})
+	}
+}
+
+func (p *Provider) Retrieve(_ context.Context, uri string, watcher confmap.WatcherFunc) (*confmap.Retrieved, error) {
+	if uri != p.URI() {
+		return nil, fmt.Errorf("%q uri is not supported by %q provider", uri, scheme)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.config == nil {
+		return nil, fmt.Errorf("no config has been set for %q", uri)
+	}
+	p.watcher = watcher
+	p.retrievals++
+	retrieval := p.retrievals
+	return confmap.NewRetrievedFromYAML(p.config, confmap.WithRetrievedClose(func(context.Context) error {
+		// The collector closes a retrieved config when it reloads or shuts
+		// down. Stop notifying it then, unless it has retrieved the config
+		// again already.
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.retrievals == retrieval {
+			p.watcher = nil
+		}
+		return nil
+	}))
+}
+
+func (*Provider) Scheme() string {
+	return scheme
+}
+
+func (p *Provider) Shutdown(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.watcher = nil
+	return nil
+}
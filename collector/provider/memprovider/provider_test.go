@@ -0,0 +1,68 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/confmap"
+)
+
+func TestRetrieve(t *testing.T) {
+	ctx := context.Background()
+	p := New()
+
+	_, err := p.Retrieve(ctx, p.URI(), nil)
+	assert.Error(t, err, "retrieved a config before it was set")
+
+	p.Set([]byte("receivers:\n  otlp: {}\n"))
+	_, err = p.Retrieve(ctx, "mem:other", nil)
+	assert.Error(t, err)
+
+	retrieved, err := p.Retrieve(ctx, p.URI(), nil)
+	require.NoError(t, err)
+	conf, err := retrieved.AsConf()
+	require.NoError(t, err)
+	assert.True(t, conf.IsSet("receivers::otlp"))
+}
+
+func TestSetNotifiesWatcher(t *testing.T) {
+	ctx := context.Background()
+	p := New()
+	p.Set([]byte("receivers: {}\n"))
+
+	changes := 0
+	watcher := func(*confmap.ChangeEvent) { changes++ }
+	first, err := p.Retrieve(ctx, p.URI(), watcher)
+	require.NoError(t, err)
+
+	p.Set([]byte("exporters: {}\n"))
+	assert.Equal(t, 1, changes)
+
+	// Closing a retrieval that has been superseded keeps the watcher of the
+	// current one.
+	second, err := p.Retrieve(ctx, p.URI(), watcher)
+	require.NoError(t, err)
+	require.NoError(t, first.Close(ctx))
+	p.Set([]byte("processors: {}\n"))
+	assert.Equal(t, 2, changes)
+
+	require.NoError(t, second.Close(ctx))
+	p.Set([]byte("extensions: {}\n"))
+	assert.Equal(t, 2, changes, "notified the watcher of a closed retrieval")
+}
@@ -0,0 +1,159 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rungmpprovider implements a confmap provider that translates a
+// RunMonitoring config into the collector config, so that the collector can
+// run standalone on a RunMonitoring file or directory:
+//
+//	rungmpcol --config rungmp:/etc/rungmp/config.yaml
+//
+// The collector reloads whenever the translated config changes.
+package rungmpprovider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/confmap"
+	"go.uber.org/zap"
+
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/confgenerator"
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/internal/configwatch"
+)
+
+const (
+	scheme = "rungmp"
+
+	// Same as the entrypoint's defaults.
+	changeDebounce  = 1 * time.Second
+	refreshInterval = 20 * time.Second
+)
+
+type provider struct {
+	logger *zap.Logger
+
+	// The port of the collector's self metrics, picked on the first
+	// retrieval. It must not change on reloads, since the config would never
+	// be the same otherwise.
+	mu              sync.Mutex
+	selfMetricsPort int
+}
+
+// NewFactory returns a factory for the rungmp provider.
+func NewFactory() confmap.ProviderFactory {
+	return confmap.NewProviderFactory(newProvider)
+}
+
+func newProvider(set confmap.ProviderSettings) confmap.Provider {
+	logger := set.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &provider{logger: logger}
+}
+
+func (p *provider) Retrieve(ctx context.Context, uri string, watcher confmap.WatcherFunc) (*confmap.Retrieved, error) {
+	if !strings.HasPrefix(uri, scheme+":") {
+		return nil, fmt.Errorf("%q uri is not supported by %q provider", uri, scheme)
+	}
+	path := uri[len(scheme)+1:]
+
+	raw, err := configwatch.ReadRaw(path)
+	if err != nil {
+		return nil, err
+	}
+	otel, err := p.translate(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	if watcher != nil {
+		go p.watch(watchCtx, path, raw, otel, watcher)
+	}
+	return confmap.NewRetrievedFromYAML([]byte(otel), confmap.WithRetrievedClose(func(context.Context) error {
+		cancel()
+		return nil
+	}))
+}
+
+// translate reads the RunMonitoring config at path and returns the collector
+// config generated from it.
+func (p *provider) translate(ctx context.Context, path string) (string, error) {
+	c, err := confgenerator.ReadConfigFromFile(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read RunMonitoring config %q: %w", path, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.selfMetricsPort == 0 {
+		if p.selfMetricsPort, err = confgenerator.GetFreePort(); err != nil {
+			return "", err
+		}
+	}
+	// There is no entrypoint whose metrics could be scraped.
+	return c.GenerateOtelConfig(ctx, p.selfMetricsPort, 0)
+}
+
+// watch notifies watcher once the config generated from path differs from
+// otel. Like in the entrypoint, a config that fails to translate is logged
+// and otherwise ignored, so that the collector keeps running with the last
+// good config.
+func (p *provider) watch(ctx context.Context, path, raw, otel string, watcher confmap.WatcherFunc) {
+	w := configwatch.New(path, changeDebounce, refreshInterval)
+	go w.Run(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.Changes:
+		}
+
+		newRaw, err := configwatch.ReadRaw(path)
+		if err != nil {
+			p.logger.Warn("Failed to read RunMonitoring config", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		if newRaw == raw {
+			continue
+		}
+		raw = newRaw
+
+		newOtel, err := p.translate(ctx, path)
+		if err != nil {
+			p.logger.Error("Rejected RunMonitoring config, keeping the last good config", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		if newOtel == otel {
+			continue
+		}
+		// The collector retrieves the config again, which starts a new
+		// watch, and closes this retrieval.
+		watcher(&confmap.ChangeEvent{})
+		return
+	}
+}
+
+func (*provider) Scheme() string {
+	return scheme
+}
+
+func (*provider) Shutdown(context.Context) error {
+	return nil
+}
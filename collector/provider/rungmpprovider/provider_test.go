@@ -0,0 +1,91 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rungmpprovider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/confmap"
+)
+
+func writeConfig(t *testing.T, path, port, interval string) {
+	t.Helper()
+	config := fmt.Sprintf(`apiVersion: monitoring.googleapis.com/v1beta
+kind: RunMonitoring
+metadata:
+  name: test
+spec:
+  endpoints:
+  - port: %s
+    interval: %s
+`, port, interval)
+	require.NoError(t, os.WriteFile(path, []byte(config), 0644))
+}
+
+func TestRetrieveTranslatesRunMonitoring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "8080", "30s")
+
+	p := newProvider(confmap.ProviderSettings{})
+	retrieved, err := p.Retrieve(context.Background(), "rungmp:"+path, nil)
+	require.NoError(t, err)
+	conf, err := retrieved.AsConf()
+	require.NoError(t, err)
+	assert.True(t, conf.IsSet("receivers::prometheus/application-metrics"))
+	require.NoError(t, retrieved.Close(context.Background()))
+}
+
+func TestRetrieveRejectsOtherSchemes(t *testing.T) {
+	p := newProvider(confmap.ProviderSettings{})
+	_, err := p.Retrieve(context.Background(), "file:/etc/rungmp/config.yaml", nil)
+	assert.Error(t, err)
+}
+
+func TestWatchNotifiesOnTranslatedChange(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "8080", "30s")
+
+	changes := make(chan *confmap.ChangeEvent, 1)
+	p := newProvider(confmap.ProviderSettings{})
+	retrieved, err := p.Retrieve(ctx, "rungmp:"+path, func(e *confmap.ChangeEvent) { changes <- e })
+	require.NoError(t, err)
+	defer retrieved.Close(ctx)
+	// Give the watcher a moment to register its watch.
+	time.Sleep(100 * time.Millisecond)
+
+	// An invalid config is not passed on to the collector.
+	writeConfig(t, path, "9090", "not-a-duration")
+	select {
+	case <-changes:
+		t.Fatal("notified about an invalid config")
+	case <-time.After(2 * changeDebounce):
+	}
+
+	writeConfig(t, path, "9090", "30s")
+	select {
+	case e := <-changes:
+		assert.NoError(t, e.Error)
+	case <-time.After(10 * time.Second):
+		t.Fatal("no change notification")
+	}
+}
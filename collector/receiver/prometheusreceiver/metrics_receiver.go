@@ -38,6 +38,7 @@ import (
 	"go.opentelemetry.io/collector/component/componentstatus"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/receiver"
+	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
 
@@ -62,6 +63,8 @@ type pReceiver struct {
 	scrapeManager    *scrape.Manager
 	discoveryManager *discovery.Manager
 	registerer       prometheus.Registerer
+
+	targetHealthRegistration metric.Registration
 }
 
 // New creates a new prometheus.Receiver reference.
@@ -306,6 +309,9 @@ func (r *pReceiver) initPrometheusComponents(ctx context.Context, logger log.Log
 	if err != nil {
 		return err
 	}
+	if err := r.registerTargetHealth(); err != nil {
+		return fmt.Errorf("failed to register target health metric: %w", err)
+	}
 
 	go func() {
 		// The scrape manager needs to wait for the configuration to be loaded before beginning
@@ -341,6 +347,11 @@ func (r *pReceiver) Shutdown(context.Context) error {
 	if r.cancelFunc != nil {
 		r.cancelFunc()
 	}
+	if r.targetHealthRegistration != nil {
+		if err := r.targetHealthRegistration.Unregister(); err != nil {
+			r.settings.Logger.Warn("Failed to unregister target health metric", zap.Error(err))
+		}
+	}
 	close(r.targetAllocatorStop)
 	r.settings.Logger.Info("collector: final scrape complete. Shutting down rest of pipeline")
 	return nil
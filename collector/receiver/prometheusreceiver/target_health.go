@@ -0,0 +1,76 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheusreceiver // import "github.com/GoogleCloudPlatform/run-gmp-sidecar/collector/receiver/prometheusreceiver"
+
+import (
+	"context"
+
+	"github.com/prometheus/prometheus/scrape"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// TargetHealthMetric reports the health of every active scrape target on
+	// the collector's own telemetry endpoint. The sidecar entrypoint uses it to
+	// tell whether the first scrape of each target has been attempted.
+	TargetHealthMetric = "otelcol_receiver_prometheus_target_health"
+
+	scopeName = "otelcol/prometheusreceiver"
+)
+
+// Values of TargetHealthMetric.
+const (
+	targetHealthUnknown = -1
+	targetHealthDown    = 0
+	targetHealthUp      = 1
+)
+
+// registerTargetHealth reports the health of the targets of the scrape
+// manager, which must have been created already.
+func (r *pReceiver) registerTargetHealth() error {
+	meter := r.settings.TelemetrySettings.MeterProvider.Meter(scopeName)
+	gauge, err := meter.Int64ObservableGauge(TargetHealthMetric,
+		metric.WithDescription("Health of the last scrape of each target: 1 if it succeeded, 0 if it failed and -1 if the target has not been scraped yet."))
+	if err != nil {
+		return err
+	}
+
+	receiverAttr := attribute.String("receiver", r.settings.ID.String())
+	r.targetHealthRegistration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for job, targets := range r.scrapeManager.TargetsActive() {
+			for _, t := range targets {
+				o.ObserveInt64(gauge, targetHealthValue(t.Health()), metric.WithAttributes(
+					receiverAttr,
+					attribute.String("job", job),
+					attribute.String("target", t.URL().String()),
+				))
+			}
+		}
+		return nil
+	}, gauge)
+	return err
+}
+
+func targetHealthValue(h scrape.TargetHealth) int64 {
+	switch h {
+	case scrape.HealthGood:
+		return targetHealthUp
+	case scrape.HealthBad:
+		return targetHealthDown
+	default:
+		return targetHealthUnknown
+	}
+}
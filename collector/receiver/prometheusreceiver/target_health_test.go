@@ -0,0 +1,28 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheusreceiver
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetHealthValue(t *testing.T) {
+	assert.EqualValues(t, 1, targetHealthValue(scrape.HealthGood))
+	assert.EqualValues(t, 0, targetHealthValue(scrape.HealthBad))
+	assert.EqualValues(t, -1, targetHealthValue(scrape.HealthUnknown))
+}
@@ -28,6 +28,9 @@ import (
 	"github.com/GoogleCloudPlatform/run-gmp-sidecar/collector/internal/env"
 	"github.com/GoogleCloudPlatform/run-gmp-sidecar/collector/internal/levelchanger"
 	"github.com/GoogleCloudPlatform/run-gmp-sidecar/collector/internal/version"
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/collector/provider/rungmpprovider"
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/internal/cloudlogging"
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/internal/diagnostics"
 
 	envprovider "go.opentelemetry.io/collector/confmap/provider/envprovider"
 	fileprovider "go.opentelemetry.io/collector/confmap/provider/fileprovider"
@@ -37,43 +40,79 @@ import (
 )
 
 func MainContext(ctx context.Context) {
+	if cloudlogging.Enabled() {
+		cloudlogging.RedirectStdLog(cloudlogging.NewLogger("collector"))
+	}
+	// The entrypoint forwards its diagnostic dump signal to the collector
+	// with --forward-dump-signal.
+	diagnostics.DumpOnSignal("collector")
 	if err := env.Create(); err != nil {
-		log.Printf("failed to build environment variables for config: %v", err)
+		log.Printf("error: failed to build environment variables for config: %v", err)
+	}
+
+	if err := run(ctx, settings()); err != nil {
+		log.Fatalf("fatal: %v", err)
 	}
+}
 
+// NewCollector returns a collector that runs in the calling process, e.g. in
+// the sidecar entrypoint. Its config is resolved from uri, using the default
+// providers and provider. The collector does not handle SIGINT and SIGTERM:
+// the caller is responsible for shutting it down.
+func NewCollector(uri string, provider confmap.ProviderFactory) (*otelcol.Collector, error) {
+	if err := env.Create(); err != nil {
+		log.Printf("error: failed to build environment variables for config: %v", err)
+	}
+
+	params := settings(provider)
+	params.ConfigProviderSettings.ResolverSettings.URIs = []string{uri}
+	params.DisableGracefulShutdown = true
+	return otelcol.NewCollector(params)
+}
+
+// settings returns the settings of the collector. The config URIs are left
+// to the caller.
+func settings(extraProviders ...confmap.ProviderFactory) otelcol.CollectorSettings {
 	info := component.BuildInfo{
 		Command:     "run-gmp-sidecar",
 		Description: "Google Cloud Run GMP Sidecar",
 		Version:     version.Version,
 	}
 
-	params := otelcol.CollectorSettings{
+	return otelcol.CollectorSettings{
 		Factories: components,
 		BuildInfo: info,
 		ConfigProviderSettings: otelcol.ConfigProviderSettings{
 			ResolverSettings: confmap.ResolverSettings{
-				ProviderFactories: []confmap.ProviderFactory{
+				ProviderFactories: append([]confmap.ProviderFactory{
 					fileprovider.NewFactory(),
 					envprovider.NewFactory(),
 					yamlprovider.NewFactory(),
 					httpprovider.NewFactory(),
 					httpsprovider.NewFactory(),
-				},
+					rungmpprovider.NewFactory(),
+				}, extraProviders...),
 			},
 		},
-		LoggingOptions: []zap.Option{
-			levelchanger.NewLevelChangerOption(
-				zapcore.ErrorLevel,
-				zapcore.DebugLevel,
-				// We would like the Error logs from this file to be logged at Debug instead.
-				// https://github.com/open-telemetry/opentelemetry-collector/blob/831373ae6c6959f6c9258ac585a2ec0ab19a074f/receiver/scraperhelper/scrapercontroller.go#L198
-				levelchanger.FilePathLevelChangeCondition("scrapercontroller.go")),
-		},
+		LoggingOptions: loggingOptions(),
 	}
+}
 
-	if err := run(ctx, params); err != nil {
-		log.Fatal(err)
+func loggingOptions() []zap.Option {
+	var opts []zap.Option
+	// The Cloud Logging core replaces the collector's own, so it needs to be
+	// the innermost core.
+	if cloudlogging.Enabled() {
+		opts = append(opts, cloudlogging.Option("collector"))
 	}
+	return append(opts,
+		levelchanger.NewLevelChangerOption(
+			zapcore.ErrorLevel,
+			zapcore.DebugLevel,
+			// We would like the Error logs from this file to be logged at Debug instead.
+			// https://github.com/open-telemetry/opentelemetry-collector/blob/831373ae6c6959f6c9258ac585a2ec0ab19a074f/receiver/scraperhelper/scrapercontroller.go#L198
+			levelchanger.FilePathLevelChangeCondition("scrapercontroller.go")),
+	)
 }
 
 func run(ctx context.Context, params otelcol.CollectorSettings) error {
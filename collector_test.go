@@ -0,0 +1,41 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubprocessCollectorConfigureWritesConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "otel.yaml")
+	c := newSubprocessCollector("unused", path)
+	assert.Equal(t, []string{"unused", "--config", path}, c.argv)
+
+	// The collector has not been started, so there is nothing to signal.
+	require.NoError(t, c.Configure("receivers: {}\n"))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "receivers: {}\n", string(data))
+
+	require.NoError(t, c.Configure("exporters: {}\n"))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "exporters: {}\n", string(data))
+}
@@ -22,11 +22,19 @@ import (
 
 type AgentSelfMetrics struct {
 	Version string
-	Service string
-	Port    int
+	// The value of the namespace label, i.e. the name of the service or job.
+	Namespace string
+	Port      int
+	// EntrypointPort is the port on which the entrypoint serves its own
+	// metrics. Entrypoint metrics are not scraped if it is 0.
+	EntrypointPort int
 }
 
 func (r AgentSelfMetrics) OTelReceiverPipeline() otel.ReceiverPipeline {
+	targets := []string{fmt.Sprintf("0.0.0.0:%d", r.Port)}
+	if r.EntrypointPort != 0 {
+		targets = append(targets, fmt.Sprintf("0.0.0.0:%d", r.EntrypointPort))
+	}
 	return otel.ReceiverPipeline{
 		Receiver: otel.Component{
 			Type: "prometheus",
@@ -36,12 +44,15 @@ func (r AgentSelfMetrics) OTelReceiverPipeline() otel.ReceiverPipeline {
 						"job_name":        "run-gmp-sidecar-self-metrics",
 						"scrape_interval": "1m",
 						"static_configs": []map[string]interface{}{{
-							"targets": []string{fmt.Sprintf("0.0.0.0:%d", r.Port)},
+							"targets": targets,
 						}},
+						// The instance of each target is its port, so that the
+						// collector and the entrypoint are separate instances.
 						"metric_relabel_configs": []map[string]interface{}{{
-							"source_labels": []string{"__address__"},
+							"source_labels": []string{"instance"},
+							"regex":         `.*:(\d+)`,
 							"target_label":  "instance",
-							"replacement":   fmt.Sprintf("%d", r.Port),
+							"replacement":   "$1",
 							"action":        "replace",
 						}},
 					}},
@@ -56,6 +67,7 @@ func (r AgentSelfMetrics) OTelReceiverPipeline() otel.ReceiverPipeline {
 				"otelcol_process_memory_rss",
 				"grpc_client_attempt_duration",
 				"googlecloudmonitoring_point_count",
+				"agent_config_reload_count",
 			),
 			otel.Transform("metric", "metric",
 				// create new count metric from histogram metric
@@ -89,11 +101,15 @@ func (r AgentSelfMetrics) OTelReceiverPipeline() otel.ReceiverPipeline {
 					// Remove service.version label
 					otel.AggregateLabels("sum", "status"),
 				),
+				otel.RenameMetric("agent_config_reload_count", "agent/config_reload_count",
+					// Remove service.version label
+					otel.AggregateLabels("sum", "status"),
+				),
 			),
 			// Add appropriate resource and metric labels.
 			otel.GCPResourceDetector(),
 			otel.TransformationMetrics(
-				otel.AddMetricLabel("namespace", r.Service),
+				otel.AddMetricLabel("namespace", r.Namespace),
 				otel.AddMetricLabel("cluster", "__run__"),
 				otel.PrefixResourceAttribute("service.instance.id", "faas.id", ":"),
 			),
@@ -22,7 +22,9 @@ import (
 )
 
 // GenerateOtelConfig generates the complete collector config including the agent self metrics.
-func (rc *RunMonitoringConfig) GenerateOtelConfig(ctx context.Context, selfMetricsPort int) (string, error) {
+// The self metrics include the entrypoint's metrics served on entrypointMetricsPort,
+// unless it is 0.
+func (rc *RunMonitoringConfig) GenerateOtelConfig(ctx context.Context, selfMetricsPort, entrypointMetricsPort int) (string, error) {
 	userAgent, _ := UserAgent("Google-Cloud-Run-GMP-Sidecar", "run-gmp", Version)
 	metricVersionLabel, _ := VersionLabel("run-gmp-sidecar")
 	receiverPipelines := make(map[string]otel.ReceiverPipeline)
@@ -34,9 +36,10 @@ func (rc *RunMonitoringConfig) GenerateOtelConfig(ctx context.Context, selfMetri
 	log.Printf("confgenerator: using port %d for self metrics", selfMetricsPort)
 
 	receiverPipelines["run-gmp-self-metrics"] = AgentSelfMetrics{
-		Version: metricVersionLabel,
-		Port:    selfMetricsPort,
-		Service: rc.Env.Service,
+		Version:        metricVersionLabel,
+		Port:           selfMetricsPort,
+		EntrypointPort: entrypointMetricsPort,
+		Namespace:      rc.Env.Namespace(),
 	}.OTelReceiverPipeline()
 
 	otelConfig, err := otel.ModularConfig{
@@ -17,9 +17,11 @@ package confgenerator_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -33,6 +35,12 @@ const (
 	goldenDir              = "golden"
 	errorGolden            = goldenDir + "/error"
 	inputFileName          = "input.yaml"
+	// Tests of config directories keep their fragments in inputDirName instead
+	// of a single inputFileName.
+	inputDirName = "input.d"
+	// Tests whose name starts with jobTestPrefix run with the metadata of a
+	// Cloud Run job instead of a service.
+	jobTestPrefix = "job-"
 )
 
 func testMetadata() *confgenerator.CloudRunEnvironment {
@@ -43,6 +51,15 @@ func testMetadata() *confgenerator.CloudRunEnvironment {
 	}
 }
 
+func testJobMetadata() *confgenerator.CloudRunEnvironment {
+	return &confgenerator.CloudRunEnvironment{
+		Job:       "test_job",
+		Execution: "test_job-abc12",
+		TaskIndex: "3",
+		TaskCount: "10",
+	}
+}
+
 func TestGoldens(t *testing.T) {
 	t.Parallel()
 	testNames := getTestsInDir(t)
@@ -82,7 +99,9 @@ func getTestsInDir(t *testing.T) []string {
 			continue
 		}
 		userSpecifiedConfPath := filepath.Join(testdataDir, testDirEntry.Name(), inputFileName)
-		if _, err := os.Stat(userSpecifiedConfPath + ".missing"); err == nil {
+		if _, err := os.Stat(filepath.Join(testdataDir, testDirEntry.Name(), inputDirName)); err == nil {
+			// Config directory
+		} else if _, err := os.Stat(userSpecifiedConfPath + ".missing"); err == nil {
 			// Intentionally missing
 		} else if _, err := os.Stat(userSpecifiedConfPath); errors.Is(err, os.ErrNotExist) {
 			// Empty directory; probably a leftover with backup files.
@@ -103,17 +122,25 @@ func generateConfigs(testDir string) (got map[string]string, err error) {
 		}
 	}()
 
-	c, err := confgenerator.ReadConfigFromFile(ctx, filepath.Join("testdata", testDir, inputFileName))
+	inputPath := filepath.Join("testdata", testDir, inputFileName)
+	if _, err := os.Stat(filepath.Join("testdata", testDir, inputDirName)); err == nil {
+		inputPath = filepath.Join("testdata", testDir, inputDirName)
+	}
+	c, err := confgenerator.ReadConfigFromFile(ctx, inputPath)
 	if err != nil {
 		return
 	}
 
-	// Use deterministic metadata and self metrics port for tests
+	// Use deterministic metadata and self metrics ports for tests
 	c.Env = testMetadata()
+	if strings.HasPrefix(testDir, jobTestPrefix) {
+		c.Env = testJobMetadata()
+	}
 	selfMetricsPort := 42
+	entrypointMetricsPort := 43
 
 	// Otel configs
-	otelGeneratedConfig, err := c.GenerateOtelConfig(ctx, selfMetricsPort)
+	otelGeneratedConfig, err := c.GenerateOtelConfig(ctx, selfMetricsPort, entrypointMetricsPort)
 	if err != nil {
 		return
 	}
@@ -170,3 +197,138 @@ func testGeneratedFiles(t *testing.T, generatedFiles map[string]string, testDir
 
 	return nil
 }
+
+func TestNamedPortFromEnvironment(t *testing.T) {
+	ctx := context.Background()
+	config := []byte(`apiVersion: monitoring.googleapis.com/v1beta
+kind: RunMonitoring
+metadata:
+  name: run-run-run
+spec:
+  endpoints:
+  - port: http-metrics
+    interval: 60s
+`)
+
+	t.Run("valid", func(t *testing.T) {
+		t.Setenv("PORT_HTTP_METRICS", "9090")
+		c, err := confgenerator.ReadConfig(ctx, config)
+		assert.NilError(t, err)
+		got, err := c.GenerateOtelConfig(ctx, 42, 43)
+		assert.NilError(t, err)
+		assert.Assert(t, strings.Contains(got, "- 0.0.0.0:9090\n"), "generated config does not scrape port 9090:\n%s", got)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("PORT_HTTP_METRICS", "http")
+		_, err := confgenerator.ReadConfig(ctx, config)
+		assert.ErrorContains(t, err, `spec.endpoints[0].port: port "http-metrics" resolves to PORT_HTTP_METRICS="http", which is not a valid port number`)
+	})
+}
+
+func TestLocalPorts(t *testing.T) {
+	t.Setenv("PORT_HTTP_METRICS", "9090")
+	c, err := confgenerator.ReadConfig(context.Background(), []byte(`apiVersion: monitoring.googleapis.com/v1beta
+kind: RunMonitoring
+metadata:
+  name: run-run-run
+spec:
+  ports:
+    admin: 8081
+  endpoints:
+  - port: 8080
+  - port: admin
+    host: localhost
+  - port: http-metrics
+    host: 127.0.0.1
+  - port: 9100
+    host: node-exporter.internal
+  - port: 8080
+    path: /other/metrics
+`))
+	assert.NilError(t, err)
+	got, err := c.LocalPorts()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, got, []string{"8080", "8081", "9090"})
+}
+
+func TestExpectedTargets(t *testing.T) {
+	c, err := confgenerator.ReadConfig(context.Background(), []byte(`apiVersion: monitoring.googleapis.com/v1
+kind: RunMonitoring
+metadata:
+  name: run-run-run
+spec:
+  endpoints:
+  - port: 8080
+  - port: 8081
+    relabeling:
+    - action: replace
+      sourceLabels:
+      - __scheme__
+      targetLabel: scheme
+  - port: 8082
+    relabeling:
+    - action: Drop
+      sourceLabels:
+      - __address__
+      regex: .*:8082
+  - port: 8083
+    relabeling:
+    - action: keep
+      sourceLabels:
+      - __metrics_path__
+      regex: /metrics
+`))
+	assert.NilError(t, err)
+	// Readiness must not wait for the targets that keep and drop may remove.
+	assert.Equal(t, c.ExpectedTargets(), 2)
+}
+
+func TestV1BetaDeprecationWarnings(t *testing.T) {
+	ctx := context.Background()
+	config := func(version, name, port string) string {
+		return fmt.Sprintf(`apiVersion: %s
+kind: RunMonitoring
+metadata:
+  name: %s
+spec:
+  endpoints:
+  - port: %s
+    relabeling:
+    - action: replace
+      sourceLabels:
+      - __scheme__
+      targetLabel: scheme
+`, version, name, port)
+	}
+	v1beta := config("monitoring.googleapis.com/v1beta", "team-a", "8080")
+	v1 := config("monitoring.googleapis.com/v1", "team-b", "9090")
+	warning := "[1:13] apiVersion: monitoring.googleapis.com/v1beta is deprecated, use monitoring.googleapis.com/v1"
+
+	t.Run("file", func(t *testing.T) {
+		fromV1Beta, err := confgenerator.ReadConfig(ctx, []byte(v1beta))
+		assert.NilError(t, err)
+		assert.Equal(t, fromV1Beta.APIVersion, "monitoring.googleapis.com/v1")
+		assert.DeepEqual(t, fromV1Beta.Warnings, []string{warning})
+
+		fromV1, err := confgenerator.ReadConfig(ctx, []byte(config("monitoring.googleapis.com/v1", "team-a", "8080")))
+		assert.NilError(t, err)
+		assert.Assert(t, fromV1.Warnings == nil, "unexpected warnings: %q", fromV1.Warnings)
+		// Apart from the warnings, both versions read the same.
+		fromV1Beta.Warnings = nil
+		assert.Assert(t, reflect.DeepEqual(fromV1Beta, fromV1), "v1beta: %+v\nv1: %+v", fromV1Beta, fromV1)
+	})
+	t.Run("directory", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, "team-a.yaml"), []byte(v1beta), 0644))
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, "team-b.yaml"), []byte(v1), 0644))
+		c, err := confgenerator.ReadConfigFromFile(ctx, dir)
+		assert.NilError(t, err)
+		assert.Equal(t, c.APIVersion, "monitoring.googleapis.com/v1")
+		assert.Equal(t, len(c.Spec.Endpoints), 2)
+		assert.DeepEqual(t, c.Warnings, []string{filepath.Join(dir, "team-a.yaml") + ": " + warning})
+	})
+	t.Run("strict", func(t *testing.T) {
+		_, err := confgenerator.ReadConfig(ctx, []byte(v1beta+"  unknown: true\n"))
+		assert.ErrorContains(t, err, `unknown field "unknown"`)
+	})
+}
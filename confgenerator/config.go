@@ -16,10 +16,16 @@ package confgenerator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/run-gmp-sidecar/confgenerator/otel"
@@ -28,41 +34,65 @@ import (
 	"github.com/prometheus/prometheus/model/relabel"
 
 	yaml "github.com/goccy/go-yaml"
+	config_util "github.com/prometheus/common/config"
 	prommodel "github.com/prometheus/common/model"
 	promconfig "github.com/prometheus/prometheus/config"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
+// RunMonitoringConfig is a RunMonitoring config of the current API version,
+// monitoring.googleapis.com/v1. Configs of older versions are converted to it
+// when they are read, see unmarshalConfig.
 type RunMonitoringConfig struct {
 	metav1.TypeMeta   `yaml:",inline"`
 	metav1.ObjectMeta `yaml:"metadata,omitempty"`
 	Spec              RunMonitoringSpec `yaml:"spec"`
 
-	Env *CloudRunEnvironment
+	Env *CloudRunEnvironment `yaml:"-"`
+	// Deprecation warnings for the config as it was read, e.g. for configs
+	// of an older API version.
+	Warnings []string `yaml:"-"`
 }
 
 // RunMonitoringSpec contains specification parameters for RunMonitoring.
 type RunMonitoringSpec struct {
 	// The endpoints to scrape on the selected pods.
 	Endpoints []ScrapeEndpoint `yaml:"endpoints"`
+	// Settings that all endpoints inherit unless they set them themselves.
+	EndpointDefaults *EndpointDefaults `yaml:"endpointDefaults,omitempty"`
 	// Labels to add to the Prometheus target for discovered endpoints.
 	TargetLabels RunTargetLabels `yaml:"targetLabels,omitempty"`
 	// Limits to apply at scrape time.
 	Limits *ScrapeLimits `yaml:"limits,omitempty"`
+	// Numbers of named ports, by name. Endpoints can refer to these ports by
+	// name. Names that are not listed here are looked up in the PORT_<NAME>
+	// environment variable of the sidecar.
+	Ports map[string]int `yaml:"ports,omitempty"`
 }
 
 // RunTargetLabels specifies the additional metadata about the target
-// users can add to their metric. Allowed options are {service, revision
-// , configuration}. If not specified, the sidecar defaults to adding all
-// of them to every metric.
+// users can add to their metric. Allowed options are {instance, service,
+// revision, configuration} for services and {instance, job, execution,
+// taskIndex} for jobs. Options that don't apply to where the sidecar runs are
+// ignored. If not specified, the sidecar defaults to adding all of them to
+// every metric.
 type RunTargetLabels struct {
 	Metadata *[]string `yaml:"metadata,omitempty"`
+	// Constant labels to add to every metric, by label name. Names must be
+	// valid Prometheus label names and must not be protected or used by the
+	// metadata labels.
+	Static map[string]string `yaml:"static,omitempty"`
 }
 
 // ScrapeEndpoint specifies a Prometheus metrics endpoint to scrape.
 type ScrapeEndpoint struct {
-	// Name or number of the port to scrape.
+	// Name or number of the port to scrape. Names are resolved through
+	// spec.ports or the PORT_<NAME> environment variable.
 	Port string `yaml:"port"`
+	// Hostname or IP address to scrape. Defaults to 0.0.0.0, i.e. the
+	// containers of the Cloud Run instance itself.
+	Host string `yaml:"host,omitempty"`
 	// Protocol scheme to use to scrape.
 	Scheme string `yaml:"scheme,omitempty"`
 	// HTTP path to scrape metrics from. Defaults to "/metrics".
@@ -72,14 +102,85 @@ type ScrapeEndpoint struct {
 	// Proxy URL to scrape through. Encoded passwords are not supported.
 	ProxyURL string `yaml:"proxyUrl,omitempty"`
 	// Interval at which to scrape metrics. Must be a valid Prometheus duration.
+	// Defaults to 30s.
 	Interval string `yaml:"interval,omitempty"`
 	// Timeout for metrics scrapes. Must be a valid Prometheus duration.
 	// Must not be larger then the scrape interval.
 	Timeout string `yaml:"timeout,omitempty"`
+	// Relabeling rules for the target of this endpoint, applied after the
+	// target labels the sidecar sets itself. Like for metricRelabeling, rules
+	// that override protected target labels are not permitted.
+	Relabeling []RelabelingRule `yaml:"relabeling,omitempty"`
 	// Relabeling rules for metrics scraped from this endpoint. Relabeling rules
 	// that override protected target labels (project_id, location, cluster,
 	// namespace, job, instance, instanceId or __address__) are not permitted.
 	MetricRelabeling []RelabelingRule `yaml:"metricRelabeling,omitempty"`
+	// HTTP basic authentication to use when scraping.
+	BasicAuth *BasicAuth `yaml:"basicAuth,omitempty"`
+	// Credentials to send in the Authorization header when scraping. Must not
+	// be used together with basicAuth.
+	Authorization *Authorization `yaml:"authorization,omitempty"`
+	// TLS configuration to use when scraping.
+	TLS *TLSConfig `yaml:"tls,omitempty"`
+
+	// The name of the config fragment the endpoint was read from, and its
+	// index in that fragment. Only set if the config was read from a
+	// directory of fragments.
+	fragment      string
+	fragmentIndex int
+	// Metric relabeling rules inherited from the endpoint defaults. They are
+	// applied before MetricRelabeling.
+	defaultMetricRelabeling []RelabelingRule
+}
+
+// EndpointDefaults holds the settings of ScrapeEndpoint that endpoints inherit.
+type EndpointDefaults struct {
+	// Protocol scheme to use to scrape.
+	Scheme string `yaml:"scheme,omitempty"`
+	// HTTP path to scrape metrics from.
+	Path string `yaml:"path,omitempty"`
+	// Interval at which to scrape metrics. Must be a valid Prometheus duration.
+	Interval string `yaml:"interval,omitempty"`
+	// Timeout for metrics scrapes. Must be a valid Prometheus duration.
+	Timeout string `yaml:"timeout,omitempty"`
+	// Relabeling rules for metrics scraped from all endpoints. Unlike the
+	// other settings they are not overridden by the rules of an endpoint,
+	// but applied before them.
+	MetricRelabeling []RelabelingRule `yaml:"metricRelabeling,omitempty"`
+}
+
+// BasicAuth configures HTTP basic authentication for scrapes. The password is
+// read from a file, e.g. a mounted secret, so that it never appears in the
+// config, the generated OTel config or the logs.
+type BasicAuth struct {
+	// The username to authenticate with.
+	Username string `yaml:"username,omitempty"`
+	// Absolute path of the file containing the password.
+	PasswordFile string `yaml:"passwordFile,omitempty"`
+}
+
+// Authorization configures the Authorization header for scrapes. Like the
+// basic auth password, the credentials are read from a file.
+type Authorization struct {
+	// The authentication type. Defaults to Bearer.
+	Type string `yaml:"type,omitempty"`
+	// Absolute path of the file containing the credentials.
+	CredentialsFile string `yaml:"credentialsFile,omitempty"`
+}
+
+// TLSConfig configures the TLS connection for scrapes. Certificates and keys
+// are read from files.
+type TLSConfig struct {
+	// Absolute path of the CA certificate to validate the server certificate with.
+	CAFile string `yaml:"caFile,omitempty"`
+	// Absolute path of the client certificate to present to the server.
+	CertFile string `yaml:"certFile,omitempty"`
+	// Absolute path of the key of the client certificate.
+	KeyFile string `yaml:"keyFile,omitempty"`
+	// Server name to validate the server certificate against.
+	ServerName string `yaml:"serverName,omitempty"`
+	// Disables validation of the server certificate.
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify,omitempty"`
 }
 
 type RelabelingRule struct {
@@ -118,11 +219,14 @@ type ScrapeLimits struct {
 	LabelValueLength uint64 `yaml:"labelValueLength,omitempty"`
 }
 
-var allowedTargetMetadata = []string{"instance", "revision", "service", "configuration"}
+var allowedTargetMetadata = []string{"instance", "revision", "service", "configuration", "job", "execution", "taskIndex"}
 
 const (
-	kind       = "RunMonitoring"
-	apiVersion = "monitoring.googleapis.com/v1beta"
+	kind = "RunMonitoring"
+	// The current API version, and the older versions that are still
+	// accepted and converted to it.
+	apiVersionV1     = "monitoring.googleapis.com/v1"
+	apiVersionV1Beta = "monitoring.googleapis.com/v1beta"
 
 	// Metric labels names that will be added to metrics based on the RunTargetLabels.Metadata
 	// configuration.
@@ -130,6 +234,9 @@ const (
 	cloudRunServiceLabel       = "service_name"
 	cloudRunRevisionLabel      = "revision_name"
 	cloudRunConfigurationLabel = "configuration_name"
+	cloudRunJobLabel           = "job_name"
+	cloudRunExecutionLabel     = "execution_name"
+	cloudRunTaskIndexLabel     = "task_index"
 )
 
 // DefaultRunMonitoringConfig creates a config that will be used by default if
@@ -137,14 +244,14 @@ const (
 // 0.0.0.0:8080/metrics for prometheus metrics.
 func DefaultRunMonitoringConfig() *RunMonitoringConfig {
 	return &RunMonitoringConfig{
-		metav1.TypeMeta{
+		TypeMeta: metav1.TypeMeta{
 			Kind:       kind,
-			APIVersion: apiVersion,
+			APIVersion: apiVersionV1,
 		},
-		metav1.ObjectMeta{
+		ObjectMeta: metav1.ObjectMeta{
 			Name: "run-gmp-sidecar",
 		},
-		RunMonitoringSpec{
+		Spec: RunMonitoringSpec{
 			Endpoints: []ScrapeEndpoint{
 				{
 					Port:     "8080",
@@ -154,47 +261,101 @@ func DefaultRunMonitoringConfig() *RunMonitoringConfig {
 			},
 			TargetLabels: RunTargetLabels{Metadata: &allowedTargetMetadata},
 		},
-		nil,
 	}
 }
 
 // ReadConfigFromFile reads the user config file and returns a RunMonitoringConfig.
 // If the user config file does not exist, or is empty - it returns the default
-// RunMonitoringConfig.
+// RunMonitoringConfig. If path is a directory, the RunMonitoring fragments in it
+// are merged into one config, see readConfigDir.
 func ReadConfigFromFile(ctx context.Context, path string) (*RunMonitoringConfig, error) {
 	config := DefaultRunMonitoringConfig()
 
 	// Fetch metadata from the available environment variables.
 	config.Env = fetchMetadata()
 
-	if _, err := os.Stat(path); err != nil {
+	info, err := os.Stat(path)
+	if err != nil {
 		if os.IsNotExist(err) {
 			log.Println("confgenerator: no user config file found, using default config")
 			return config, nil
 		}
 		return nil, fmt.Errorf("failed to retrieve the user config file %q: %w", path, err)
 	}
+	if info.IsDir() {
+		return readConfigDir(ctx, path)
+	}
 
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return ReadConfig(ctx, data)
+}
+
+// ReadConfig parses a RunMonitoring config from data, e.g. a config that was
+// not read from a file. If data is empty it returns the default
+// RunMonitoringConfig.
+func ReadConfig(ctx context.Context, data []byte) (*RunMonitoringConfig, error) {
+	config := DefaultRunMonitoringConfig()
+
+	// Fetch metadata from the available environment variables.
+	config.Env = fetchMetadata()
+
 	log.Printf("confgenerator: using RunMonitoring config:\n%s", string(data))
 
 	// Unmarshal the user config over the default config. If some options are unspecified
 	// the collector uses the default settings for those options. For example, if not specified
 	// targetLabels is set to {"revision", "service", "configuration"}
-	if err := yaml.UnmarshalContext(ctx, data, config, yaml.Strict()); err != nil {
+	if err := unmarshalConfig(ctx, data, config); err != nil {
 		return nil, err
 	}
+	for _, w := range config.Warnings {
+		log.Printf("confgenerator: warning: %s", w)
+	}
 
 	// Validate the RunMonitoring config
 	if err := config.Validate(); err != nil {
-		return nil, err
+		return nil, locateError(data, err)
 	}
 	return config, nil
 }
 
+// unmarshalConfig strictly unmarshals data over config, in the schema of the
+// API version data declares, or of the version of config if data declares
+// none. Configs of older versions are converted to the current version, and
+// their deprecation warnings are added to config.Warnings.
+func unmarshalConfig(ctx context.Context, data []byte, config *RunMonitoringConfig) error {
+	var meta struct {
+		metav1.TypeMeta `yaml:",inline"`
+	}
+	if err := yaml.UnmarshalContext(ctx, data, &meta); err != nil {
+		return err
+	}
+	version := meta.APIVersion
+	if version == "" {
+		version = config.APIVersion
+	}
+
+	switch version {
+	case apiVersionV1:
+		return yaml.UnmarshalContext(ctx, data, config, yaml.Strict())
+	case apiVersionV1Beta:
+		old := runMonitoringToV1Beta(config)
+		if err := yaml.UnmarshalContext(ctx, data, old, yaml.Strict()); err != nil {
+			return err
+		}
+		converted, warnings := old.toV1()
+		converted.Env = config.Env
+		for _, w := range warnings {
+			converted.Warnings = append(converted.Warnings, locateError(data, w).Error())
+		}
+		*config = *converted
+		return nil
+	}
+	return locateError(data, &ConfigError{Path: "apiVersion", Err: fmt.Errorf("must be %s or %s", apiVersionV1, apiVersionV1Beta)})
+}
+
 // OTelReceiverPipeline creates the appropriate OTel pipeline translated from the
 // RunMonitoringConfig.
 func (rc *RunMonitoringConfig) OTelReceiverPipeline() (*otel.ReceiverPipeline, error) {
@@ -203,10 +364,12 @@ func (rc *RunMonitoringConfig) OTelReceiverPipeline() (*otel.ReceiverPipeline, e
 		return nil, err
 	}
 
-	// Prefix the `instance` resource label with the faas.id.
-	processors := []otel.Component{
-		otel.GCPResourceDetector(),
-		otel.TransformationMetrics(otel.PrefixResourceAttribute("service.instance.id", "faas.id", ":")),
+	processors := []otel.Component{otel.GCPResourceDetector()}
+	// Prefix the `instance` resource label with the faas.id. Jobs identify
+	// their targets by execution and task index instead, see
+	// endpointScrapeConfig.
+	if !rc.Env.IsJob() {
+		processors = append(processors, otel.TransformationMetrics(otel.PrefixResourceAttribute("service.instance.id", "faas.id", ":")))
 	}
 
 	// If the users configure to add the instance metadata, add it as a metric label.
@@ -237,13 +400,18 @@ func (rc *RunMonitoringConfig) OTelReceiverPipeline() (*otel.ReceiverPipeline, e
 	}, nil
 }
 
-// Validate validates the RunMonitoring config.
+// Validate validates the RunMonitoring config, including the translation of
+// its endpoints to Prometheus scrape configs. Errors in specific fields are
+// returned as a *ConfigError.
 func (rc *RunMonitoringConfig) Validate() error {
-	if rc.APIVersion != apiVersion {
-		return fmt.Errorf("apiVersion must be %s", apiVersion)
+	if rc.APIVersion != apiVersionV1 {
+		return &ConfigError{Path: "apiVersion", Err: fmt.Errorf("must be %s", apiVersionV1)}
 	}
 	if rc.Kind != kind {
-		return fmt.Errorf("kind must be %s", kind)
+		return &ConfigError{Path: "kind", Err: fmt.Errorf("must be %s", kind)}
+	}
+	if _, err := rc.scrapeConfigs(); err != nil {
+		return err
 	}
 
 	return nil
@@ -251,39 +419,194 @@ func (rc *RunMonitoringConfig) Validate() error {
 
 // scrapeConfigs converts the given RunMonitoringConfig to an equivalent set of Prometheus ScrapeConfigs.
 func (rc *RunMonitoringConfig) scrapeConfigs() (res []*promconfig.ScrapeConfig, err error) {
-	for i := range rc.Spec.Endpoints {
-		c, err := rc.endpointScrapeConfig(i)
+	if rc.Env == nil {
+		return nil, fmt.Errorf("metadata from Cloud Run was not found")
+	}
+	metadataLabels, err := rc.metadataLabels()
+	if err != nil {
+		return nil, err
+	}
+	relabelCfgs := relabelingsForMetadata(metadataLabels, rc.Env)
+	if err := rc.validateStaticLabels(); err != nil {
+		return nil, err
+	}
+	relabelCfgs = append(relabelCfgs, relabelingsForStaticLabels(rc.Spec.TargetLabels.Static)...)
+	if err := rc.validatePorts(); err != nil {
+		return nil, err
+	}
+	if err := validateEndpointDefaults(rc.Spec.EndpointDefaults); err != nil {
+		return nil, withPath("spec.endpointDefaults", err)
+	}
+
+	for i, ep := range rc.Spec.Endpoints {
+		ep = applyEndpointDefaults(ep, rc.Spec.EndpointDefaults)
+		if ep.Port, err = rc.resolvePort(ep.Port); err != nil {
+			return nil, withPath(fmt.Sprintf("spec.endpoints[%d].port", i), err)
+		}
+		jobName, cfgName := fmt.Sprintf("run-gmp-sidecar-%d", i), rc.Name
+		if ep.fragment != "" {
+			jobName, cfgName = fmt.Sprintf("run-gmp-sidecar-%s-%d", ep.fragment, ep.fragmentIndex), ep.fragment
+		}
+		c, err := endpointScrapeConfig(
+			jobName,
+			cfgName,
+			ep,
+			relabelCfgs,
+			rc.Spec.Limits,
+			rc.Env,
+		)
 		if err != nil {
-			return nil, fmt.Errorf("invalid definition for endpoint with index %d: %w", i, err)
+			return nil, withPath(fmt.Sprintf("spec.endpoints[%d]", i), err)
 		}
 		res = append(res, c)
 	}
 	return res, nil
 }
 
-// endpointScrapeConfig creates a scrape config for the endpoint specified.
-func (rc *RunMonitoringConfig) endpointScrapeConfig(index int) (*promconfig.ScrapeConfig, error) {
+// validateEndpointDefaults validates the fields of d that can be validated
+// on their own, so that errors in them are attributed to d rather than to the
+// endpoints inheriting them.
+func validateEndpointDefaults(d *EndpointDefaults) error {
+	if d == nil {
+		return nil
+	}
+	if d.Interval != "" {
+		if _, err := prommodel.ParseDuration(d.Interval); err != nil {
+			return withPath("interval", fmt.Errorf("invalid scrape interval: %w", err))
+		}
+	}
+	if d.Timeout != "" {
+		if _, err := prommodel.ParseDuration(d.Timeout); err != nil {
+			return withPath("timeout", fmt.Errorf("invalid scrape timeout: %w", err))
+		}
+	}
+	for i, r := range d.MetricRelabeling {
+		if _, err := convertRelabelingRule(r); err != nil {
+			return withPath(fmt.Sprintf("metricRelabeling[%d]", i), err)
+		}
+	}
+	return nil
+}
+
+// applyEndpointDefaults returns ep with the fields it leaves unset taken from
+// d. The metric relabeling rules of d are kept apart from the ones of ep, so
+// that errors in the latter point to the right rule.
+func applyEndpointDefaults(ep ScrapeEndpoint, d *EndpointDefaults) ScrapeEndpoint {
+	if d == nil {
+		return ep
+	}
+	if ep.Scheme == "" {
+		ep.Scheme = d.Scheme
+	}
+	if ep.Path == "" {
+		ep.Path = d.Path
+	}
+	if ep.Interval == "" {
+		ep.Interval = d.Interval
+	}
+	if ep.Timeout == "" {
+		ep.Timeout = d.Timeout
+	}
+	ep.defaultMetricRelabeling = d.MetricRelabeling
+	return ep
+}
+
+// validatePorts validates the numbers of the named ports in spec.ports.
+func (rc *RunMonitoringConfig) validatePorts() error {
+	for _, name := range sortedKeys(rc.Spec.Ports) {
+		if n := rc.Spec.Ports[name]; n < 1 || n > 65535 {
+			return &ConfigError{Path: "spec.ports." + name, Err: fmt.Errorf("port %d is out of range", n)}
+		}
+	}
+	return nil
+}
+
+// resolvePort returns the number of port, which is either a port number or
+// the name of a port. Named ports are looked up in spec.ports and then in the
+// PORT_<NAME> environment variable.
+func (rc *RunMonitoringConfig) resolvePort(port string) (string, error) {
+	if port == "" {
+		return "", errors.New("must be set")
+	}
+	if n, err := strconv.Atoi(port); err == nil {
+		if n < 1 || n > 65535 {
+			return "", fmt.Errorf("port %d is out of range", n)
+		}
+		return port, nil
+	}
+	if n, ok := rc.Spec.Ports[port]; ok {
+		return strconv.Itoa(n), nil
+	}
+	envName := portEnvName(port)
+	if v, ok := rc.Env.Ports[envName]; ok {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("port %q resolves to %s=%q, which is not a valid port number", port, envName, v)
+		}
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown port name %q, set it in spec.ports or in the %s environment variable", port, envName)
+}
+
+// LocalPorts returns the port numbers of the endpoints that are scraped on the
+// Cloud Run instance itself, i.e. the ports the app serves its metrics on.
+// Every port is only returned once.
+func (rc *RunMonitoringConfig) LocalPorts() ([]string, error) {
+	if rc.Env == nil {
+		return nil, fmt.Errorf("metadata from Cloud Run was not found")
+	}
+	var ports []string
+	for i, ep := range rc.Spec.Endpoints {
+		if ep.Host != "" {
+			host, err := parseHost(ep.Host)
+			if err != nil {
+				return nil, withPath(fmt.Sprintf("spec.endpoints[%d].host", i), err)
+			}
+			if !isLocalHost(host) {
+				continue
+			}
+		}
+		port, err := rc.resolvePort(ep.Port)
+		if err != nil {
+			return nil, withPath(fmt.Sprintf("spec.endpoints[%d].port", i), err)
+		}
+		if !slices.Contains(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// ExpectedTargets returns the number of scrape targets the endpoints are
+// certain to produce: one per endpoint, except for the endpoints whose target
+// relabeling has keep or drop rules, which may remove their target.
+func (rc *RunMonitoringConfig) ExpectedTargets() int {
+	n := len(rc.Spec.Endpoints)
+	for _, ep := range rc.Spec.Endpoints {
+		for _, r := range ep.Relabeling {
+			if a := relabel.Action(strings.ToLower(r.Action)); a == relabel.Keep || a == relabel.Drop {
+				n--
+				break
+			}
+		}
+	}
+	return n
+}
+
+// metadataLabels returns the set of Cloud Run metadata to add as target labels.
+func (rc *RunMonitoringConfig) metadataLabels() (map[string]struct{}, error) {
 	metadataLabels := map[string]struct{}{}
 	if rc.Spec.TargetLabels.Metadata != nil {
-		for _, l := range *rc.Spec.TargetLabels.Metadata {
+		for i, l := range *rc.Spec.TargetLabels.Metadata {
 			if !contains(allowedTargetMetadata, l) {
-				return nil, fmt.Errorf("metadata label %q not allowed, must be one of %v", l, allowedTargetMetadata)
+				return nil, &ConfigError{
+					Path: fmt.Sprintf("spec.targetLabels.metadata[%d]", i),
+					Err:  fmt.Errorf("metadata label %q not allowed, must be one of %v", l, allowedTargetMetadata),
+				}
 			}
 			metadataLabels[l] = struct{}{}
 		}
 	}
-	relabelCfgs := relabelingsForMetadata(metadataLabels, rc.Env)
-
-	jobName := fmt.Sprintf("run-gmp-sidecar-%d", index)
-
-	return endpointScrapeConfig(
-		jobName,
-		rc.Name,
-		rc.Spec.Endpoints[index],
-		relabelCfgs,
-		rc.Spec.Limits,
-		rc.Env,
-	)
+	return metadataLabels, nil
 }
 
 func relabelingsForMetadata(keys map[string]struct{}, env *CloudRunEnvironment) (res []*relabel.Config) {
@@ -291,6 +614,31 @@ func relabelingsForMetadata(keys map[string]struct{}, env *CloudRunEnvironment)
 		return
 	}
 
+	if env.IsJob() {
+		if _, ok := keys["job"]; ok {
+			res = append(res, &relabel.Config{
+				Action:      relabel.Replace,
+				Replacement: env.Job,
+				TargetLabel: cloudRunJobLabel,
+			})
+		}
+		if _, ok := keys["execution"]; ok {
+			res = append(res, &relabel.Config{
+				Action:      relabel.Replace,
+				Replacement: env.Execution,
+				TargetLabel: cloudRunExecutionLabel,
+			})
+		}
+		if _, ok := keys["taskIndex"]; ok {
+			res = append(res, &relabel.Config{
+				Action:      relabel.Replace,
+				Replacement: env.TaskIndex,
+				TargetLabel: cloudRunTaskIndexLabel,
+			})
+		}
+		return res
+	}
+
 	if _, ok := keys["service"]; ok {
 		res = append(res, &relabel.Config{
 			Action:      relabel.Replace,
@@ -315,18 +663,69 @@ func relabelingsForMetadata(keys map[string]struct{}, env *CloudRunEnvironment)
 	return res
 }
 
+// validateStaticLabels validates the names and values of the static target
+// labels.
+func (rc *RunMonitoringConfig) validateStaticLabels() error {
+	reserved := append(protectedLabels[:len(protectedLabels):len(protectedLabels)],
+		cloudRunServiceLabel, cloudRunRevisionLabel, cloudRunConfigurationLabel,
+		cloudRunJobLabel, cloudRunExecutionLabel, cloudRunTaskIndexLabel)
+	for _, name := range sortedKeys(rc.Spec.TargetLabels.Static) {
+		path := "spec.targetLabels.static." + name
+		switch {
+		case !prommodel.LabelName(name).IsValidLegacy() || strings.HasPrefix(name, "__"):
+			return &ConfigError{Path: path, Err: fmt.Errorf("%q is not a valid label name", name)}
+		case contains(reserved, name):
+			return &ConfigError{Path: path, Err: fmt.Errorf("label %q is set by the sidecar and cannot be static", name)}
+		case rc.Spec.TargetLabels.Static[name] == "":
+			return &ConfigError{Path: path, Err: errors.New("value must not be empty")}
+		}
+	}
+	return nil
+}
+
+func relabelingsForStaticLabels(labels map[string]string) (res []*relabel.Config) {
+	for _, name := range sortedKeys(labels) {
+		res = append(res, &relabel.Config{
+			Action:      relabel.Replace,
+			Replacement: labels[name],
+			TargetLabel: name,
+		})
+	}
+	return res
+}
+
 func endpointScrapeConfig(id, cfgName string, ep ScrapeEndpoint, relabelCfgs []*relabel.Config, limits *ScrapeLimits, env *CloudRunEnvironment) (*promconfig.ScrapeConfig, error) {
-	if env == nil {
-		return nil, fmt.Errorf("metadata from Cloud Run was not found")
+	host := defaultHost
+	if ep.Host != "" {
+		var err error
+		if host, err = parseHost(ep.Host); err != nil {
+			return nil, withPath("host", err)
+		}
+	}
+	// Targets of the Cloud Run instance itself are identified by their port,
+	// which is prefixed with the instance ID later on. In jobs they are
+	// identified by the execution and task index instead, which, unlike the
+	// instance ID, stay the same when a task is retried. Other hosts are
+	// shared by all instances and are identified by their address, like
+	// Prometheus does by default.
+	instance := ep.Port
+	switch {
+	case !isLocalHost(host):
+		instance = net.JoinHostPort(host, ep.Port)
+	case env.IsJob():
+		instance = strings.Join([]string{env.Execution, env.TaskIndex, ep.Port}, ":")
 	}
+
 	labelSet := make(map[prommodel.LabelName]prommodel.LabelValue)
-	labelSet[prommodel.AddressLabel] = prommodel.LabelValue("0.0.0.0:" + ep.Port)
+	labelSet[prommodel.AddressLabel] = prommodel.LabelValue(net.JoinHostPort(host, ep.Port))
 	discoveryCfgs := discovery.Configs{
 		discovery.StaticConfig{
 			&targetgroup.Group{Targets: []prommodel.LabelSet{labelSet}},
 		},
 	}
-	relabelCfgs = append(relabelCfgs,
+	// Copy the shared metadata relabelings so that endpoints don't append to
+	// each other's rules.
+	relabelCfgs = append(relabelCfgs[:len(relabelCfgs):len(relabelCfgs)],
 		&relabel.Config{
 			Action:      relabel.Replace,
 			Replacement: cfgName,
@@ -340,33 +739,45 @@ func endpointScrapeConfig(id, cfgName string, ep ScrapeEndpoint, relabelCfgs []*
 		&relabel.Config{
 			Action:      relabel.Replace,
 			TargetLabel: "namespace",
-			Replacement: env.Service,
+			Replacement: env.Namespace(),
 		},
-		// The `instance` label will be <faas.id>:<port> in the final metric.
-		// But since <faas.id> is unavailable until the gcp resource detector
-		// runs later in the pipeline we just populate the port for now.
+		// For local targets of services the `instance` label will be
+		// <faas.id>:<port> in the final metric. But since <faas.id> is
+		// unavailable until the gcp resource detector runs later in the
+		// pipeline we just populate the port for now.
 		//
 		// See the usage of PrefixResourceAttribute for when the rest of the
 		// instance label is filled in.
 		&relabel.Config{
 			Action:      relabel.Replace,
 			TargetLabel: "instance",
-			Replacement: ep.Port,
+			Replacement: instance,
 		},
 	)
 
+	for i, r := range ep.Relabeling {
+		rcfgs, err := convertRelabelingRule(r)
+		if err != nil {
+			return nil, withPath(fmt.Sprintf("relabeling[%d]", i), err)
+		}
+		relabelCfgs = append(relabelCfgs, rcfgs...)
+	}
+
+	if ep.Interval == "" {
+		ep.Interval = defaultScrapeInterval
+	}
 	interval, err := prommodel.ParseDuration(ep.Interval)
 	if err != nil {
-		return nil, fmt.Errorf("invalid scrape interval: %w", err)
+		return nil, withPath("interval", fmt.Errorf("invalid scrape interval: %w", err))
 	}
 	timeout := interval
 	if ep.Timeout != "" {
 		timeout, err = prommodel.ParseDuration(ep.Timeout)
 		if err != nil {
-			return nil, fmt.Errorf("invalid scrape timeout: %w", err)
+			return nil, withPath("timeout", fmt.Errorf("invalid scrape timeout: %w", err))
 		}
 		if timeout > interval {
-			return nil, fmt.Errorf("scrape timeout %v must not be greater than scrape interval %v", timeout, interval)
+			return nil, withPath("timeout", fmt.Errorf("scrape timeout %v must not be greater than scrape interval %v", timeout, interval))
 		}
 	}
 
@@ -376,12 +787,25 @@ func endpointScrapeConfig(id, cfgName string, ep ScrapeEndpoint, relabelCfgs []*
 	}
 
 	var metricRelabelCfgs []*relabel.Config
-	for _, r := range ep.MetricRelabeling {
-		rcfg, err := convertRelabelingRule(r)
+	for _, r := range ep.defaultMetricRelabeling {
+		// The endpoint defaults have been validated already.
+		rcfgs, err := convertRelabelingRule(r)
 		if err != nil {
 			return nil, err
 		}
-		metricRelabelCfgs = append(metricRelabelCfgs, rcfg)
+		metricRelabelCfgs = append(metricRelabelCfgs, rcfgs...)
+	}
+	for i, r := range ep.MetricRelabeling {
+		rcfgs, err := convertRelabelingRule(r)
+		if err != nil {
+			return nil, withPath(fmt.Sprintf("metricRelabeling[%d]", i), err)
+		}
+		metricRelabelCfgs = append(metricRelabelCfgs, rcfgs...)
+	}
+
+	httpCfg, err := convertHTTPClientConfig(ep)
+	if err != nil {
+		return nil, err
 	}
 
 	scrapeCfg := &promconfig.ScrapeConfig{
@@ -395,6 +819,7 @@ func endpointScrapeConfig(id, cfgName string, ep ScrapeEndpoint, relabelCfgs []*
 		RelabelConfigs:          relabelCfgs,
 		MetricRelabelConfigs:    metricRelabelCfgs,
 		ScrapeProtocols:         promconfig.DefaultScrapeProtocols,
+		HTTPClientConfig:        httpCfg,
 	}
 	if limits != nil {
 		scrapeCfg.SampleLimit = uint(limits.Samples)
@@ -421,9 +846,113 @@ func endpointScrapeConfig(id, cfgName string, ep ScrapeEndpoint, relabelCfgs []*
 	return scrapeCfg, nil
 }
 
-// convertRelabelingRule converts the rule to a relabel configuration. An error is returned
-// if the rule would modify one of the protected labels.
-func convertRelabelingRule(r RelabelingRule) (*relabel.Config, error) {
+// Host scraped by endpoints that don't set one.
+const defaultHost = "0.0.0.0"
+
+// Interval at which endpoints are scraped if neither they nor the endpoint
+// defaults set one.
+const defaultScrapeInterval = "30s"
+
+// parseHost validates the host of an endpoint, which must be a hostname or an
+// IP address. IPv6 addresses may be enclosed in brackets.
+func parseHost(host string) (string, error) {
+	if ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")); ip != nil {
+		return ip.String(), nil
+	}
+	// Hostnames are case insensitive.
+	name := strings.ToLower(host)
+	if len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return "", fmt.Errorf("%q is neither an IP address nor a valid hostname", host)
+	}
+	return name, nil
+}
+
+// isLocalHost reports whether host refers to the Cloud Run instance the
+// sidecar runs on.
+func isLocalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+// convertHTTPClientConfig converts the authentication and TLS settings of ep
+// to the HTTP client config of a Prometheus scrape config. Secrets are only
+// ever referenced by file, so the result is safe to write out and log.
+func convertHTTPClientConfig(ep ScrapeEndpoint) (cfg config_util.HTTPClientConfig, err error) {
+	if ep.BasicAuth != nil {
+		if ep.BasicAuth.Username == "" && ep.BasicAuth.PasswordFile == "" {
+			return cfg, withPath("basicAuth", errors.New("username or passwordFile must be set"))
+		}
+		if err := checkSecretFile(ep.BasicAuth.PasswordFile); err != nil {
+			return cfg, withPath("basicAuth.passwordFile", err)
+		}
+		cfg.BasicAuth = &config_util.BasicAuth{
+			Username:     ep.BasicAuth.Username,
+			PasswordFile: ep.BasicAuth.PasswordFile,
+		}
+	}
+	if ep.Authorization != nil {
+		if ep.BasicAuth != nil {
+			return cfg, withPath("authorization", errors.New("must not be set together with basicAuth"))
+		}
+		authType := strings.TrimSpace(ep.Authorization.Type)
+		if authType == "" {
+			authType = "Bearer"
+		}
+		if strings.EqualFold(authType, "basic") {
+			return cfg, withPath("authorization.type", errors.New("must not be Basic, use basicAuth instead"))
+		}
+		if ep.Authorization.CredentialsFile == "" {
+			return cfg, withPath("authorization", errors.New("credentialsFile must be set"))
+		}
+		if err := checkSecretFile(ep.Authorization.CredentialsFile); err != nil {
+			return cfg, withPath("authorization.credentialsFile", err)
+		}
+		cfg.Authorization = &config_util.Authorization{
+			Type:            authType,
+			CredentialsFile: ep.Authorization.CredentialsFile,
+		}
+	}
+	if ep.TLS != nil {
+		for _, f := range []struct{ field, path string }{
+			{"caFile", ep.TLS.CAFile},
+			{"certFile", ep.TLS.CertFile},
+			{"keyFile", ep.TLS.KeyFile},
+		} {
+			if err := checkSecretFile(f.path); err != nil {
+				return cfg, withPath("tls."+f.field, err)
+			}
+		}
+		if (ep.TLS.CertFile == "") != (ep.TLS.KeyFile == "") {
+			return cfg, withPath("tls", errors.New("certFile and keyFile must be set together"))
+		}
+		cfg.TLSConfig = config_util.TLSConfig{
+			CAFile:             ep.TLS.CAFile,
+			CertFile:           ep.TLS.CertFile,
+			KeyFile:            ep.TLS.KeyFile,
+			ServerName:         ep.TLS.ServerName,
+			InsecureSkipVerify: ep.TLS.InsecureSkipVerify,
+		}
+	}
+	return cfg, nil
+}
+
+// checkSecretFile checks the path of a file that credentials or certificates
+// are read from. The collector resolves relative paths against the directory
+// of its generated config, so only absolute paths are allowed.
+func checkSecretFile(path string) error {
+	if path != "" && !filepath.IsAbs(path) {
+		return fmt.Errorf("path %q must be absolute", path)
+	}
+	return nil
+}
+
+// convertRelabelingRule converts the rule to relabel configurations. Usually
+// that is a single configuration, see protectLabelMap for the exception. An
+// error is returned if the rule would modify one of the protected labels.
+func convertRelabelingRule(r RelabelingRule) ([]*relabel.Config, error) {
 	if contains(r.SourceLabels, cloudRunInstanceLabel) {
 		return nil, fmt.Errorf("cannot relabel with action %q using source label %q", r.Action, cloudRunInstanceLabel)
 	}
@@ -474,16 +1003,90 @@ func convertRelabelingRule(r RelabelingRule) (*relabel.Config, error) {
 			return nil, fmt.Errorf("regex %s would drop at least one of the protected labels %s", r.Regex, strings.Join(protectedLabels, ", "))
 		}
 	case relabel.LabelMap:
-		// It is difficult to prove for certain that labelmap does not override a protected label.
-		// Thus we just prohibit its use for now.
-		// The most feasible way to support this would probably be store all protected labels
-		// in __tmp_protected_<name> via a replace rule, then apply labelmap, then replace the
-		// __tmp label back onto the protected label.
-		return nil, fmt.Errorf("relabeling with action %q not allowed", r.Action)
+		// It is difficult to prove for certain that labelmap does not override a protected
+		// label, so the protected labels are restored after it instead. That only works if
+		// the labelmap can't write into the stash the protected labels are restored from,
+		// see protectLabelMap.
+		if !isSafeLabelMapReplacement(r.Replacement) {
+			return nil, fmt.Errorf("cannot relabel with action %q using replacement %q, it must be a single capture group reference like $1 or start with a letter", r.Action, r.Replacement)
+		}
+		return protectLabelMap(rcfg), nil
 	case relabel.Keep, relabel.Drop:
 		// These actions don't modify a series and are OK.
 	default:
 		return nil, fmt.Errorf("unknown relabeling action %q", r.Action)
 	}
-	return rcfg, nil
+	return []*relabel.Config{rcfg}, nil
+}
+
+// Prefix of the labels that protectLabelMap stashes the protected labels in.
+const protectedLabelStashPrefix = "__tmp_protected_"
+
+// protectedLabelStash returns the label that protectLabelMap stashes the
+// protected label l in. The trailing underscore keeps one stash label from
+// being a prefix of another, e.g. for instance and instanceId.
+func protectedLabelStash(l string) string {
+	return protectedLabelStashPrefix + l + "_"
+}
+
+// singleGroupReference matches a replacement that only references a single
+// capture group, e.g. $1, ${1} or $name.
+var singleGroupReference = regexp.MustCompile(`^\$(\w+|\{\w+\})$`)
+
+// isSafeLabelMapReplacement reports whether a labelmap rule with the given
+// replacement cannot write into the stash of protectLabelMap. A replacement
+// that starts with a letter can't produce a name starting with "__". A single
+// capture group reference only produces a part of an existing label name, and
+// protectLabelMap drops the scraped labels that contain the stash prefix. The
+// empty replacement defaults to $1.
+func isSafeLabelMapReplacement(replacement string) bool {
+	if replacement == "" || singleGroupReference.MatchString(replacement) {
+		return true
+	}
+	c := replacement[0]
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// protectLabelMap wraps the labelmap rule rcfg so that it cannot change the
+// protected labels: they are stashed in __tmp_protected_<name>_ labels before
+// the labelmap and restored from there after it. Protected labels that were
+// not set before are deleted again. Finally the stash is dropped.
+//
+// Labels that contain the stash prefix are dropped before stashing, so that a
+// labelmap can't map a scraped label like source___tmp_protected_job onto
+// the stash. Together with isSafeLabelMapReplacement that leaves the stash
+// labels themselves as the only ones a labelmap could map onto the stash, and
+// those only map onto themselves.
+func protectLabelMap(rcfg *relabel.Config) []*relabel.Config {
+	// Unlike in convertRelabelingRule the regex and replacement are set
+	// explicitly, since the defaults don't apply to a source label.
+	all := relabel.MustNewRegexp("(.*)")
+
+	stash := []*relabel.Config{{
+		Action: relabel.LabelDrop,
+		Regex:  relabel.MustNewRegexp(".*" + protectedLabelStashPrefix + ".*"),
+	}}
+	var restore []*relabel.Config
+	for _, l := range protectedLabels {
+		stash = append(stash, &relabel.Config{
+			Action:       relabel.Replace,
+			SourceLabels: prommodel.LabelNames{prommodel.LabelName(l)},
+			Regex:        all,
+			TargetLabel:  protectedLabelStash(l),
+			Replacement:  "$1",
+		})
+		restore = append(restore, &relabel.Config{
+			Action:       relabel.Replace,
+			SourceLabels: prommodel.LabelNames{prommodel.LabelName(protectedLabelStash(l))},
+			Regex:        all,
+			TargetLabel:  l,
+			Replacement:  "$1",
+		})
+	}
+	res := append(stash, rcfg)
+	res = append(res, restore...)
+	return append(res, &relabel.Config{
+		Action: relabel.LabelDrop,
+		Regex:  relabel.MustNewRegexp(protectedLabelStashPrefix + ".*"),
+	})
 }
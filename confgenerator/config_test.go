@@ -0,0 +1,140 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confgenerator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"gopkg.in/yaml.v2"
+	"gotest.tools/v3/assert"
+)
+
+func TestLabelMapKeepsProtectedLabels(t *testing.T) {
+	rcfgs, err := convertRelabelingRule(RelabelingRule{Action: "labelmap", Regex: "source_(.+)"})
+	assert.NilError(t, err)
+
+	// Apply the rules the way the collector reads them from the generated config.
+	data, err := yaml.Marshal(rcfgs)
+	assert.NilError(t, err)
+	var parsed []*relabel.Config
+	assert.NilError(t, yaml.Unmarshal(data, &parsed))
+
+	// Every protected label the labelmap would map onto, plus one it may map.
+	mapped := map[string]string{
+		"source_location":   "evil",
+		"source_cluster":    "evil",
+		"source_namespace":  "evil",
+		"source_job":        "evil",
+		"source_instance":   "evil",
+		"source_instanceId": "evil",
+		"source_team":       "team-a",
+	}
+	withMapped := func(m map[string]string) labels.Labels {
+		for k, v := range mapped {
+			m[k] = v
+		}
+		return labels.FromMap(m)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		input, want labels.Labels
+	}{
+		{
+			name: "protected labels set",
+			input: withMapped(map[string]string{
+				"__name__":   "up",
+				"location":   "us-central1",
+				"cluster":    "__run__",
+				"namespace":  "test_service",
+				"job":        "run-run-run",
+				"instance":   "8080",
+				"instanceId": "0123456789",
+			}),
+			want: withMapped(map[string]string{
+				"__name__":   "up",
+				"location":   "us-central1",
+				"cluster":    "__run__",
+				"namespace":  "test_service",
+				"job":        "run-run-run",
+				"instance":   "8080",
+				"instanceId": "0123456789",
+				"team":       "team-a",
+			}),
+		},
+		{
+			// location and instanceId are only added after metric relabeling,
+			// so the labelmap must not create them either.
+			name: "protected labels unset",
+			input: withMapped(map[string]string{
+				"__name__":  "up",
+				"cluster":   "__run__",
+				"namespace": "test_service",
+				"job":       "run-run-run",
+				"instance":  "8080",
+			}),
+			want: withMapped(map[string]string{
+				"__name__":  "up",
+				"cluster":   "__run__",
+				"namespace": "test_service",
+				"job":       "run-run-run",
+				"instance":  "8080",
+				"team":      "team-a",
+			}),
+		},
+		{
+			// With the default replacement $1 these would be mapped onto the
+			// stash and restored into job.
+			name: "scraped stash labels",
+			input: withMapped(map[string]string{
+				"__name__":                    "up",
+				"cluster":                     "__run__",
+				"namespace":                   "test_service",
+				"job":                         "run-run-run",
+				"instance":                    "8080",
+				"source___tmp_protected_job":  "evil",
+				"source___tmp_protected_job_": "evil",
+			}),
+			want: withMapped(map[string]string{
+				"__name__":  "up",
+				"cluster":   "__run__",
+				"namespace": "test_service",
+				"job":       "run-run-run",
+				"instance":  "8080",
+				"team":      "team-a",
+			}),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, keep := relabel.Process(tc.input, parsed...)
+			assert.Assert(t, keep)
+			assert.Equal(t, got.String(), tc.want.String())
+		})
+	}
+
+	// A labelmap onto the stash would restore its own values into the
+	// protected labels, e.g. evil_job=x into job.
+	for _, replacement := range []string{protectedLabelStashPrefix + "$1", "_$1", "$1_$2", "${1}_"} {
+		_, err = convertRelabelingRule(RelabelingRule{Action: "labelmap", Regex: "evil_(.*)", Replacement: replacement})
+		assert.ErrorContains(t, err, fmt.Sprintf("using replacement %q", replacement))
+	}
+	for _, replacement := range []string{"$1", "${1}", "$name", "${name}", "evil_$1"} {
+		_, err = convertRelabelingRule(RelabelingRule{Action: "labelmap", Regex: "evil_(?P<name>.*)", Replacement: replacement})
+		assert.NilError(t, err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confgenerator
+
+import (
+	"fmt"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// ConfigError is an error in a specific field of a RunMonitoring config.
+type ConfigError struct {
+	// Path of the offending field, e.g. spec.endpoints[1].metricRelabeling[0].
+	Path string
+	// Position of the field in the YAML source. Both are 0 if unknown.
+	Line   int
+	Column int
+
+	Err error
+}
+
+// Error formats the error like the YAML parser does, so that all errors of
+// a config file look alike.
+func (e *ConfigError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("[%d:%d] %s: %v", e.Line, e.Column, e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// withPath attributes err to the field at path. If err is already attributed
+// to a field, path is treated as the parent of that field.
+func withPath(path string, err error) error {
+	if ce, ok := err.(*ConfigError); ok {
+		if !strings.HasPrefix(ce.Path, "[") {
+			path += "."
+		}
+		return &ConfigError{Path: path + ce.Path, Err: ce.Err}
+	}
+	return &ConfigError{Path: path, Err: err}
+}
+
+// locateError fills in the position of err in the YAML source data, if err is
+// a ConfigError whose field can be found in data. Other errors are returned
+// as is.
+func locateError(data []byte, err error) error {
+	ce, ok := err.(*ConfigError)
+	if !ok || ce.Line > 0 {
+		return err
+	}
+	path, perr := yaml.PathString("$." + ce.Path)
+	if perr != nil {
+		return err
+	}
+	file, perr := parser.ParseBytes(data, 0)
+	if perr != nil {
+		return err
+	}
+	node, perr := path.FilterFile(file)
+	if perr != nil || node == nil {
+		return err
+	}
+
+	// The token of a mapping is the colon of its first entry. Point at the
+	// first key instead, which is where a reader would expect the mapping to
+	// start.
+	tok := node.GetToken()
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		if len(n.Values) > 0 {
+			tok = n.Values[0].Key.GetToken()
+		}
+	case *ast.MappingValueNode:
+		tok = n.Key.GetToken()
+	}
+	if tok == nil || tok.Position == nil {
+		return err
+	}
+	return &ConfigError{Path: ce.Path, Line: tok.Position.Line, Column: tok.Position.Column, Err: ce.Err}
+}
@@ -0,0 +1,204 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confgenerator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// ConfigFragments returns the RunMonitoring fragments in dir and its
+// subdirectories in lexical order. Fragments are files ending in .yaml or
+// .yml. Hidden files and directories are skipped, which also skips the
+// versioned data directories of Secret Manager volumes.
+func ConfigFragments(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml":
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// configFragment is a single RunMonitoring document of a config directory.
+type configFragment struct {
+	path   string
+	data   []byte
+	config *RunMonitoringConfig
+}
+
+// readConfigDir reads all fragments in dir and merges them into one
+// RunMonitoringConfig.
+//
+// The endpoints of all fragments are merged. Each endpoint is scraped as a
+// job named after its fragment's metadata.name and its index within the
+// fragment, so that adding or removing a fragment does not rename the jobs of
+// the others. Spec-level fields like targetLabels and limits apply to all
+// endpoints, so fragments that set them must agree on their values. The same
+// holds for the numbers of named ports set by more than one fragment.
+// Fragments may use different API versions, each is converted to the current
+// one on its own.
+func readConfigDir(ctx context.Context, dir string) (*RunMonitoringConfig, error) {
+	config := DefaultRunMonitoringConfig()
+	config.Env = fetchMetadata()
+
+	paths, err := ConfigFragments(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the user config directory %q: %w", dir, err)
+	}
+
+	var fragments []configFragment
+	for _, path := range paths {
+		f, err := readConfigFragment(ctx, path, config.Env)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if f != nil {
+			fragments = append(fragments, *f)
+		}
+	}
+	if len(fragments) == 0 {
+		log.Printf("confgenerator: no config fragments found in %q, using default config", dir)
+		return config, nil
+	}
+
+	config.Spec = RunMonitoringSpec{}
+	// The fragment that first set each of the spec-level fields.
+	var targetLabelsFrom, limitsFrom *configFragment
+	namesFrom := map[string]string{}
+	portsFrom := map[string]*configFragment{}
+	for i := range fragments {
+		f := &fragments[i]
+		spec := f.config.Spec
+
+		if other, ok := namesFrom[f.config.Name]; ok {
+			return nil, fragmentError(f, "metadata.name", fmt.Errorf("%q is already used by %s", f.config.Name, other))
+		}
+		namesFrom[f.config.Name] = f.path
+		for _, w := range f.config.Warnings {
+			config.Warnings = append(config.Warnings, fmt.Sprintf("%s: %s", f.path, w))
+		}
+
+		if spec.TargetLabels.Metadata != nil || spec.TargetLabels.Static != nil {
+			if targetLabelsFrom != nil && !reflect.DeepEqual(spec.TargetLabels, config.Spec.TargetLabels) {
+				return nil, fragmentError(f, "spec.targetLabels", fmt.Errorf("conflicts with the targetLabels of %s", targetLabelsFrom.path))
+			}
+			config.Spec.TargetLabels = spec.TargetLabels
+			targetLabelsFrom = f
+		}
+		if spec.Limits != nil {
+			if limitsFrom != nil && !reflect.DeepEqual(spec.Limits, config.Spec.Limits) {
+				return nil, fragmentError(f, "spec.limits", fmt.Errorf("conflicts with the limits of %s", limitsFrom.path))
+			}
+			config.Spec.Limits = spec.Limits
+			limitsFrom = f
+		}
+		for _, name := range sortedKeys(spec.Ports) {
+			if other, ok := portsFrom[name]; ok && spec.Ports[name] != config.Spec.Ports[name] {
+				return nil, fragmentError(f, "spec.ports."+name, fmt.Errorf("conflicts with the port of the same name in %s", other.path))
+			}
+			if config.Spec.Ports == nil {
+				config.Spec.Ports = map[string]int{}
+			}
+			config.Spec.Ports[name] = spec.Ports[name]
+			portsFrom[name] = f
+		}
+
+		// The endpoint defaults of a fragment only apply to its own endpoints.
+		for j, ep := range spec.Endpoints {
+			ep = applyEndpointDefaults(ep, spec.EndpointDefaults)
+			ep.fragment = f.config.Name
+			ep.fragmentIndex = j
+			config.Spec.Endpoints = append(config.Spec.Endpoints, ep)
+		}
+	}
+	if config.Spec.TargetLabels.Metadata == nil {
+		config.Spec.TargetLabels.Metadata = DefaultRunMonitoringConfig().Spec.TargetLabels.Metadata
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	return config, nil
+}
+
+// readConfigFragment reads and validates a single fragment. It returns nil
+// for empty files.
+func readConfigFragment(ctx context.Context, path string, env *CloudRunEnvironment) (*configFragment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	log.Printf("confgenerator: using RunMonitoring config fragment %s:\n%s", path, string(data))
+
+	// Unlike a single config file, fragments are not unmarshalled over the
+	// default config. Otherwise the default endpoint would end up in every
+	// fragment, and fields left unset could not be told apart from fields
+	// set to the default.
+	config := &RunMonitoringConfig{}
+	if err := unmarshalConfig(ctx, data, config); err != nil {
+		return nil, err
+	}
+	for _, w := range config.Warnings {
+		log.Printf("confgenerator: warning: %s: %s", path, w)
+	}
+	if config.Name == "" {
+		return nil, locateError(data, &ConfigError{Path: "metadata", Err: fmt.Errorf("name must be set in config fragments")})
+	}
+
+	// Validate the fragment on its own, so that errors point into its file.
+	check := *config
+	check.Env = env
+	if check.Spec.TargetLabels.Metadata == nil {
+		check.Spec.TargetLabels.Metadata = DefaultRunMonitoringConfig().Spec.TargetLabels.Metadata
+	}
+	if err := check.Validate(); err != nil {
+		return nil, locateError(data, err)
+	}
+	return &configFragment{path: path, data: data, config: config}, nil
+}
+
+// fragmentError returns err attributed to the field at path of fragment f.
+func fragmentError(f *configFragment, path string, err error) error {
+	return fmt.Errorf("%s: %w", f.path, locateError(f.data, &ConfigError{Path: path, Err: err}))
+}
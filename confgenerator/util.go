@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -26,20 +27,64 @@ import (
 )
 
 // CloudRunEnvironment captures some environment metadata that is captures using environment variables.
-// See https://cloud.google.com/run/docs/container-contract#services-env-vars for more information.
+// See https://cloud.google.com/run/docs/container-contract#services-env-vars and
+// https://cloud.google.com/run/docs/container-contract#jobs-env-vars for more information.
 // Note that PORT is not made available to sidecar containers, and so is omitted from this struct.
 type CloudRunEnvironment struct {
 	Service       string
 	Revision      string
 	Configuration string
+	// Only set in Cloud Run jobs.
+	Job       string
+	Execution string
+	TaskIndex string
+	TaskCount string
+	// Ports holds the PORT_<NAME> environment variables that named ports are
+	// resolved with, by variable name.
+	Ports map[string]string
 }
 
+// Prefix of the environment variables that set the number of a named port.
+const portEnvPrefix = "PORT_"
+
 func fetchMetadata() *CloudRunEnvironment {
-	return &CloudRunEnvironment{
+	env := &CloudRunEnvironment{
 		Service:       os.Getenv("K_SERVICE"),
 		Revision:      os.Getenv("K_REVISION"),
 		Configuration: os.Getenv("K_CONFIGURATION"),
+		Job:           os.Getenv("CLOUD_RUN_JOB"),
+		Execution:     os.Getenv("CLOUD_RUN_EXECUTION"),
+		TaskIndex:     os.Getenv("CLOUD_RUN_TASK_INDEX"),
+		TaskCount:     os.Getenv("CLOUD_RUN_TASK_COUNT"),
+		Ports:         map[string]string{},
+	}
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(name, portEnvPrefix) {
+			env.Ports[name] = value
+		}
 	}
+	return env
+}
+
+// IsJob reports whether the sidecar runs in a Cloud Run job rather than a
+// service.
+func (e *CloudRunEnvironment) IsJob() bool {
+	return e.Job != ""
+}
+
+// Namespace returns the value of the namespace label, which is the name of the
+// service or job.
+func (e *CloudRunEnvironment) Namespace() string {
+	if e.IsJob() {
+		return e.Job
+	}
+	return e.Service
+}
+
+// portEnvName returns the name of the environment variable that sets the
+// number of the named port, e.g. PORT_HTTP_METRICS for http-metrics.
+func portEnvName(port string) string {
+	return portEnvPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(port))
 }
 
 var versionLabelTemplate = template.Must(template.New("versionlabel").Parse(`{{.Prefix}}@{{.AgentVersion}}`))
@@ -114,6 +159,16 @@ var protectedLabels = []string{
 	"__address__",
 }
 
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func isProtectedLabel(s string) bool {
 	return contains(protectedLabels, s)
 }
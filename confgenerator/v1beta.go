@@ -0,0 +1,67 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confgenerator
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// runMonitoringV1Beta is the monitoring.googleapis.com/v1beta version of
+// RunMonitoringConfig. v1beta configs are strictly unmarshalled into it and
+// converted to the current version right away, so it is only used to read
+// them.
+type runMonitoringV1Beta struct {
+	metav1.TypeMeta   `yaml:",inline"`
+	metav1.ObjectMeta `yaml:"metadata,omitempty"`
+	Spec              runMonitoringSpecV1Beta `yaml:"spec"`
+}
+
+// runMonitoringSpecV1Beta is the v1beta spec. v1 is the stable release of
+// v1beta and did not change its fields, so it has the same definition as
+// RunMonitoringSpec. Once a field changes in v1, the types it affects have to
+// be copied here first, so that each version keeps accepting only its own
+// fields, and toV1 has to convert them.
+type runMonitoringSpecV1Beta RunMonitoringSpec
+
+// runMonitoringToV1Beta converts rc to v1beta, so that a v1beta config can be
+// unmarshalled over it.
+func runMonitoringToV1Beta(rc *RunMonitoringConfig) *runMonitoringV1Beta {
+	c := &runMonitoringV1Beta{
+		TypeMeta:   rc.TypeMeta,
+		ObjectMeta: rc.ObjectMeta,
+		Spec:       runMonitoringSpecV1Beta(rc.Spec),
+	}
+	c.APIVersion = apiVersionV1Beta
+	return c
+}
+
+// toV1 converts c to the current version. It returns a deprecation warning
+// for every part of c that v1 replaced, as a ConfigError with its v1beta path.
+// So far that is only the API version itself.
+func (c *runMonitoringV1Beta) toV1() (*RunMonitoringConfig, []error) {
+	rc := &RunMonitoringConfig{
+		TypeMeta:   c.TypeMeta,
+		ObjectMeta: c.ObjectMeta,
+		Spec:       RunMonitoringSpec(c.Spec),
+	}
+	rc.APIVersion = apiVersionV1
+
+	warnings := []error{
+		&ConfigError{Path: "apiVersion", Err: fmt.Errorf("%s is deprecated, use %s", apiVersionV1Beta, apiVersionV1)},
+	}
+	return rc, warnings
+}
@@ -0,0 +1,194 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/confgenerator"
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/internal/configwatch"
+)
+
+// Environment variable that holds an inline RunMonitoring config. It takes
+// precedence over the user config file.
+const inlineConfigEnv = "RUN_GMP_CONFIG"
+
+// configSource provides the RunMonitoring config to the entrypoint. All
+// sources feed the same reload path: whenever Watch notifies, the raw config
+// is fetched again and, if it changed, parsed and applied.
+type configSource interface {
+	// Fetch returns the current raw config. It is only used to tell whether
+	// the config changed since it was last fetched.
+	Fetch(ctx context.Context) (string, error)
+	// Parse parses the raw config returned by Fetch.
+	Parse(ctx context.Context, raw string) (*confgenerator.RunMonitoringConfig, error)
+	// Watch returns a channel that receives a value whenever the config may
+	// have changed, until ctx is cancelled. Sources that never change return
+	// a nil channel.
+	Watch(ctx context.Context) <-chan struct{}
+	// String names the source in logs and messages.
+	String() string
+}
+
+// newConfigSource returns the source for the current settings: the inline
+// config if RUN_GMP_CONFIG is not empty, an HTTP(S) source if the user config
+// is a URL, and the user config file or directory otherwise.
+func newConfigSource(lookupEnv func(string) (string, bool)) configSource {
+	// An empty variable, e.g. left behind by a deployment template, does not
+	// count as a config.
+	if inline, ok := lookupEnv(inlineConfigEnv); ok && strings.TrimSpace(inline) != "" {
+		return inlineConfigSource(inline)
+	}
+	if strings.HasPrefix(userConfigFile, "http://") || strings.HasPrefix(userConfigFile, "https://") {
+		return newURLConfigSource(userConfigFile, configRefreshInterval)
+	}
+	return fileConfigSource(userConfigFile)
+}
+
+// fileConfigSource reads the config from a file, or from a directory of
+// config fragments, that is watched for changes.
+type fileConfigSource string
+
+func (s fileConfigSource) Fetch(context.Context) (string, error) {
+	return configwatch.ReadRaw(string(s))
+}
+
+func (s fileConfigSource) Parse(ctx context.Context, _ string) (*confgenerator.RunMonitoringConfig, error) {
+	// Directories of fragments can only be parsed from disk.
+	return confgenerator.ReadConfigFromFile(ctx, string(s))
+}
+
+func (s fileConfigSource) Watch(ctx context.Context) <-chan struct{} {
+	watcher := configwatch.New(string(s), configChangeDebounce, configRefreshInterval)
+	go watcher.Run(ctx)
+	return watcher.Changes
+}
+
+func (s fileConfigSource) String() string {
+	return string(s)
+}
+
+// inlineConfigSource is a config passed in an environment variable. It can't
+// change for the lifetime of the container.
+type inlineConfigSource string
+
+func (s inlineConfigSource) Fetch(context.Context) (string, error) {
+	return string(s), nil
+}
+
+func (s inlineConfigSource) Parse(ctx context.Context, raw string) (*confgenerator.RunMonitoringConfig, error) {
+	return confgenerator.ReadConfig(ctx, []byte(raw))
+}
+
+func (s inlineConfigSource) Watch(context.Context) <-chan struct{} {
+	return nil
+}
+
+func (s inlineConfigSource) String() string {
+	return inlineConfigEnv
+}
+
+// urlConfigSource polls the config from an HTTP(S) URL. It uses conditional
+// requests, so that an unchanged config is not downloaded again.
+type urlConfigSource struct {
+	url          string
+	pollInterval time.Duration
+	client       *http.Client
+
+	mu           sync.Mutex
+	body         string
+	etag         string
+	lastModified string
+}
+
+func newURLConfigSource(url string, pollInterval time.Duration) *urlConfigSource {
+	return &urlConfigSource{
+		url:          url,
+		pollInterval: pollInterval,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *urlConfigSource) Fetch(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return "", err
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	if s.lastModified != "" {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch config from %q: %v", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return s.body, nil
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("failed to read config from %q: %v", s.url, err)
+		}
+		s.body = string(data)
+		s.etag = resp.Header.Get("ETag")
+		s.lastModified = resp.Header.Get("Last-Modified")
+		return s.body, nil
+	default:
+		return "", fmt.Errorf("failed to fetch config from %q: %s", s.url, resp.Status)
+	}
+}
+
+func (s *urlConfigSource) Parse(ctx context.Context, raw string) (*confgenerator.RunMonitoringConfig, error) {
+	return confgenerator.ReadConfig(ctx, []byte(raw))
+}
+
+func (s *urlConfigSource) Watch(ctx context.Context) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changes
+}
+
+func (s *urlConfigSource) String() string {
+	return s.url
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigSource(t *testing.T) {
+	restoreSettings(t)
+	noEnv := func(string) (string, bool) { return "", false }
+	env := func(v string) func(string) (string, bool) {
+		return func(k string) (string, bool) { return v, k == inlineConfigEnv }
+	}
+
+	userConfigFile = "/etc/rungmp/config.yaml"
+	assert.Equal(t, fileConfigSource("/etc/rungmp/config.yaml"), newConfigSource(noEnv))
+	assert.Equal(t, fileConfigSource("/etc/rungmp/config.yaml"), newConfigSource(env("  ")))
+	assert.Equal(t, inlineConfigSource("kind: RunMonitoring"), newConfigSource(env("kind: RunMonitoring")))
+
+	userConfigFile = "https://example.com/config.yaml"
+	assert.IsType(t, &urlConfigSource{}, newConfigSource(noEnv))
+}
+
+func TestURLConfigSourceConditionalGet(t *testing.T) {
+	const lastModified = "Wed, 21 Oct 2026 07:28:00 GMT"
+	version := 1
+	var requests []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		etag := fmt.Sprintf(`"v%d"`, version)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		fmt.Fprintf(w, "config v%d", version)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s := newURLConfigSource(srv.URL, time.Hour)
+
+	raw, err := s.Fetch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "config v1", raw)
+	assert.Empty(t, requests[0].Header.Get("If-None-Match"))
+
+	// The second fetch is answered with 304 and returns the cached config.
+	raw, err = s.Fetch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "config v1", raw)
+	assert.Equal(t, `"v1"`, requests[1].Header.Get("If-None-Match"))
+	assert.Equal(t, lastModified, requests[1].Header.Get("If-Modified-Since"))
+
+	version = 2
+	raw, err = s.Fetch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "config v2", raw)
+}
+
+func TestURLConfigSourceError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := newURLConfigSource(srv.URL, time.Hour).Fetch(context.Background())
+	assert.ErrorContains(t, err, "404 Not Found")
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/internal/diagnostics"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// writeDiagnostics writes the entrypoint's diagnostic dump to w. The scrape
+// target and exporter sections are taken from the collector's self metrics.
+func writeDiagnostics(ctx context.Context, w io.Writer) error {
+	page := sidecarStatus.Page()
+	families, err := scrapeSelfMetrics(ctx, selfMetricsClient, selfMetricsURL())
+
+	return diagnostics.Write(w, "entrypoint",
+		diagnostics.Section{Title: "status", Write: func(w io.Writer) {
+			writeStatus(w, page)
+		}},
+		diagnostics.Section{Title: "scrape targets", Write: func(w io.Writer) {
+			if err != nil {
+				fmt.Fprintln(w, err)
+				return
+			}
+			writeScrapeTargets(w, families[targetHealthMetric])
+		}},
+		diagnostics.Section{Title: "exporters", Write: func(w io.Writer) {
+			if err != nil {
+				fmt.Fprintln(w, err)
+				return
+			}
+			writeExporters(w, families)
+		}},
+		diagnostics.Section{Title: "generated OTel config", Write: func(w io.Writer) {
+			io.WriteString(w, page.OtelConfig)
+		}},
+	)
+}
+
+func writeStatus(w io.Writer, page statusPage) {
+	c := page.Collector
+	fmt.Fprintf(w, "collector: running=%t healthy=%t in_process=%t", c.Running, c.Healthy, c.InProcess)
+	if c.Running {
+		fmt.Fprintf(w, " pid=%d uptime=%s", c.PID, c.Uptime)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "self metrics port: %d\n", page.SelfMetricsPort)
+	fmt.Fprintf(w, "config source: %s\n", page.ConfigSource)
+	switch r := page.LastReload; {
+	case r == nil:
+		fmt.Fprintln(w, "last reload: none")
+	case r.Succeeded:
+		fmt.Fprintf(w, "last reload: succeeded at %s\n", r.Time.UTC().Format(time.RFC3339))
+	default:
+		fmt.Fprintf(w, "last reload: failed at %s: %s\n", r.Time.UTC().Format(time.RFC3339), r.Error)
+	}
+}
+
+// writeScrapeTargets writes the health of every scrape target, sorted by
+// receiver, job and target.
+func writeScrapeTargets(w io.Writer, health *dto.MetricFamily) {
+	var lines []string
+	for _, m := range health.GetMetric() {
+		state := "unknown"
+		switch v := m.GetGauge().GetValue(); {
+		case v > 0:
+			state = "up"
+		case v == 0:
+			state = "down"
+		}
+		lines = append(lines, fmt.Sprintf("%s job=%s target=%s health=%s",
+			labelValue(m, "receiver"), labelValue(m, "job"), labelValue(m, "target"), state))
+	}
+	if len(lines) == 0 {
+		fmt.Fprintln(w, "no scrape targets")
+		return
+	}
+	sort.Strings(lines)
+	for _, l := range lines {
+		fmt.Fprintln(w, l)
+	}
+}
+
+// writeExporters writes the queue depth of every exporter, and the export
+// progress of all of them.
+func writeExporters(w io.Writer, families map[string]*dto.MetricFamily) {
+	var lines []string
+	for _, m := range families[exporterQueueSizeMetric].GetMetric() {
+		lines = append(lines, fmt.Sprintf("%s queue_size=%v", labelValue(m, "exporter"), m.GetGauge().GetValue()))
+	}
+	sort.Strings(lines)
+	for _, l := range lines {
+		fmt.Fprintln(w, l)
+	}
+	s := flushStateOf(families)
+	fmt.Fprintf(w, "total: queued=%v in_flight=%v sent_points=%v failed_points=%v\n", s.queued, s.inFlight, s.sent, s.failed)
+}
@@ -0,0 +1,66 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteScrapeTargetsAndExporters(t *testing.T) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(selfMetrics(3, 10, 8, 500) + `# TYPE otelcol_receiver_prometheus_target_health gauge
+otelcol_receiver_prometheus_target_health{receiver="prometheus/application-metrics",job="run-gmp-sidecar-1",target="http://0.0.0.0:8081/metrics"} -1
+otelcol_receiver_prometheus_target_health{receiver="prometheus/application-metrics",job="run-gmp-sidecar-0",target="http://0.0.0.0:8080/metrics"} 0
+otelcol_receiver_prometheus_target_health{receiver="prometheus/run-gmp-self-metrics",job="run-gmp-sidecar-self-metrics",target="http://0.0.0.0:42/metrics"} 1
+`))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	writeScrapeTargets(&buf, families[targetHealthMetric])
+	assert.Equal(t, `prometheus/application-metrics job=run-gmp-sidecar-0 target=http://0.0.0.0:8080/metrics health=down
+prometheus/application-metrics job=run-gmp-sidecar-1 target=http://0.0.0.0:8081/metrics health=unknown
+prometheus/run-gmp-self-metrics job=run-gmp-sidecar-self-metrics target=http://0.0.0.0:42/metrics health=up
+`, buf.String())
+
+	buf.Reset()
+	writeExporters(&buf, families)
+	assert.Equal(t, `googlemanagedprometheus queue_size=3
+total: queued=3 in_flight=2 sent_points=500 failed_points=0
+`, buf.String())
+}
+
+func TestWriteDiagnosticsWithoutSelfMetrics(t *testing.T) {
+	newTestStatus(t)
+	prev := selfMetricsPort
+	t.Cleanup(func() { selfMetricsPort = prev })
+	// Nothing listens on port 1.
+	selfMetricsPort = 1
+
+	var buf bytes.Buffer
+	require.NoError(t, writeDiagnostics(context.Background(), &buf))
+	dump := buf.String()
+	assert.Contains(t, dump, "--- status ---\ncollector: running=false")
+	assert.Contains(t, dump, "config source: /etc/rungmp/config.yaml\nlast reload: none\n")
+	assert.Contains(t, dump, "--- scrape targets ---\nfailed to scrape collector self metrics")
+	assert.Contains(t, dump, "--- generated OTel config ---\nreceivers: {}\n")
+	assert.Contains(t, dump, "--- goroutines ---\n")
+}
@@ -16,165 +16,340 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"reflect"
 	"syscall"
 	"time"
 
 	"github.com/GoogleCloudPlatform/run-gmp-sidecar/confgenerator"
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/internal/diagnostics"
 )
 
 // Create channel to listen for signals.
 var signalChan chan (os.Signal) = make(chan os.Signal, 1)
+
+// Receives the signal that asks for a diagnostic dump.
+var dumpChan = make(chan os.Signal, 1)
+
+// Settings of the entrypoint. See registerFlags for how to override them.
 var userConfigFile = "/etc/rungmp/config.yaml"
 var otelConfigFile = "/run/rungmp/otel.yaml"
 var configRefreshInterval = 20 * time.Second
+var configChangeDebounce = 1 * time.Second
 var selfMetricsPort = 0
 var livenessProbePort = 13133
 var livenessProbePath = "/liveness"
+var startupProbePath = "/startup"
+var readinessProbePath = "/ready"
 var delayLivenessProbe = 5 * time.Second
+var exporterStallTimeout = 2 * time.Minute
 
-func getRawUserConfig(userConfigFile string) (string, error) {
-	_, err := os.Stat(userConfigFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return "", nil
-		}
-		return "", fmt.Errorf("failed to stat file %q: %v", userConfigFile, err)
-	}
+// Exit once the app is done, for run-to-completion containers such as Cloud
+// Run job tasks. The app signals that it is done by creating appDoneFile, or
+// by closing its metrics ports if no appDoneFile is set.
+var exitWithApp = false
+var appDoneFile = ""
+var appPollInterval = 1 * time.Second
 
-	data, err := ioutil.ReadFile(userConfigFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to read file %q: %v", userConfigFile, err)
-	}
+// Forward the diagnostic dump signal to the collector subprocess. Only a
+// collector built from collector/cmd/rungmpcol writes dumps, the signal
+// terminates other builds such as the one from the distribution manifest.
+var forwardDumpSignal = false
 
-	return string(data), nil
-}
+// Cloud Run sends SIGKILL 10s after SIGTERM. Give the collector most of that
+// time for its final scrape and flush, and kill it ourselves shortly before so
+// that the outcome still gets logged.
+var shutdownTimeout = 8 * time.Second
 
-// Generate OTel config from RunMonitoring config. Returns an error if
-// generation of OTel configs failed.
-func generateOtelConfig(ctx context.Context, userConfigFile string) error {
-	// Pick up RunMonitoring configuration from mounted volume that is tied to
-	// secret manager.  Translate it from RunMonitoring to OTel.
-	c, err := confgenerator.ReadConfigFromFile(ctx, userConfigFile)
-	if err != nil {
-		log.Fatal(err)
-	}
+// The OTel collector, see otelCollector. It is created once the flags have
+// been parsed.
+var collector otelCollector
 
+// Tracks the export progress of the collector for the liveness probe.
+var flushes = newFlushWatcher(selfMetricsURL, exporterStallTimeout)
+
+// renderOtelConfig returns the OTel config for the RunMonitoring config c,
+// picking a self metrics port first if none has been configured.
+func renderOtelConfig(ctx context.Context, c *confgenerator.RunMonitoringConfig) (string, error) {
+	var err error
 	if selfMetricsPort == 0 {
 		selfMetricsPort, err = confgenerator.GetFreePort()
 		if err != nil {
-			return err
+			return "", err
 		}
 	}
+	return c.GenerateOtelConfig(ctx, selfMetricsPort, livenessProbePort)
+}
 
-	// Create the OTel config and write it to disk
-	otel, err := c.GenerateOtelConfig(ctx, selfMetricsPort)
+// applyConfig translates the RunMonitoring config c and hands the result to
+// the collector. A config that fails to translate is rejected and the
+// collector keeps running with the previous one. It returns the OTel config.
+func applyConfig(ctx context.Context, c *confgenerator.RunMonitoringConfig) (string, error) {
+	otel, err := renderOtelConfig(ctx, c)
 	if err != nil {
-		return err
-	}
-	if err := os.MkdirAll(filepath.Dir(otelConfigFile), 0755); err != nil {
-		return fmt.Errorf("failed to create directory for %q: %v", otelConfigFile, err)
+		return "", err
 	}
-	if err := ioutil.WriteFile(otelConfigFile, []byte(otel), 0644); err != nil {
-		return fmt.Errorf("failed to write file to %q: %v", otelConfigFile, err)
+	if err := collector.Configure(otel); err != nil {
+		return "", err
 	}
-
-	return nil
+	return otel, nil
 }
 
 // The container is allocated CPU for the duration of the healthcheck. Delaying
 // the response to this probe allows the container to complete telemetry flushes
-// that may have been throttled.
-//
-// TODO(b/342463831): Use a more reliable way of checking if telemetry is being
-// flushed instead of using a static sleep.
-func healthcheckHandler(_ http.ResponseWriter, _ *http.Request) {
-	time.Sleep(delayLivenessProbe)
+// that may have been throttled. The probe returns as soon as the collector has
+// nothing left to export, and waits at most delayLivenessProbe.
+func healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	// A crash looping collector will not recover by itself. Fail the probe so
+	// that Cloud Run restarts the whole container.
+	if !collector.Healthy() {
+		http.Error(w, "collector is crash looping", http.StatusServiceUnavailable)
+		return
+	}
+	if err := flushes.Wait(r.Context(), delayLivenessProbe); err != nil {
+		log.Printf("entrypoint: error: failing liveness probe: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}
 }
 
 func main() {
 	// SIGINT handles Ctrl+C locally.
 	// SIGTERM handles Cloud Run termination signal.
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	diagnostics.Notify(dumpChan)
 	ctx := context.Background()
 
-	lastRawConfig, err := getRawUserConfig(userConfigFile)
+	registerFlags(flag.CommandLine)
+	if err := applyEnvOverrides(flag.CommandLine, os.LookupEnv); err != nil {
+		log.Fatalf("entrypoint: fatal: %v", err)
+	}
+	flag.Parse()
+	if err := setupLogging(); err != nil {
+		log.Fatalf("entrypoint: fatal: %v", err)
+	}
+
+	source := newConfigSource(os.LookupEnv)
+
+	if flag.Arg(0) == validateCommand {
+		var sources []configSource
+		for _, path := range flag.Args()[1:] {
+			sources = append(sources, fileConfigSource(path))
+		}
+		if len(sources) == 0 {
+			sources = []configSource{source}
+		}
+		// The translation logs its progress, which only gets in the way of
+		// the results here.
+		log.SetOutput(io.Discard)
+		if !validateConfigs(ctx, sources, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if dryRun {
+		raw, err := source.Fetch(ctx)
+		if err != nil {
+			log.Fatalf("entrypoint: fatal: %v", err)
+		}
+		c, err := source.Parse(ctx, raw)
+		if err != nil {
+			log.Fatalf("entrypoint: fatal: %v", err)
+		}
+		otel, err := renderOtelConfig(ctx, c)
+		if err != nil {
+			log.Fatalf("entrypoint: fatal: %v", err)
+		}
+		fmt.Print(otel)
+		return
+	}
+	if inProcess {
+		collector = newInProcessCollector()
+	} else {
+		stderr, flags, err := collectorLogging()
+		if err != nil {
+			log.Fatalf("entrypoint: fatal: %v", err)
+		}
+		c := newSubprocessCollector("./rungmpcol", otelConfigFile, flags...)
+		c.stderr = stderr
+		collector = c
+	}
+
+	// Pick up RunMonitoring configuration, by default from the mounted volume
+	// that is tied to secret manager.
+	log.Printf("entrypoint: reading RunMonitoring config from %s", source)
+	lastRawConfig, err := source.Fetch(ctx)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("entrypoint: fatal: %v", err)
+	}
+	lastConfig, err := source.Parse(ctx, lastRawConfig)
+	if err != nil {
+		log.Fatalf("entrypoint: fatal: %v", err)
 	}
 
-	// Generate the OTel config for the first time.
-	err = generateOtelConfig(ctx, userConfigFile)
+	// Generate the OTel config for the first time. There is no previous config
+	// to fall back to yet, so an invalid config is fatal here.
+	otel, err := applyConfig(ctx, lastConfig)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("entrypoint: fatal: %v", err)
 	}
+	expectedTargets.Store(int64(lastConfig.ExpectedTargets()))
+	sidecarStatus.SetConfig(source, lastConfig, otel)
 
 	entrypointMux := http.NewServeMux()
 	entrypointMux.HandleFunc(livenessProbePath, healthcheckHandler)
+	entrypointMux.HandleFunc(startupProbePath, startupHandler)
+	entrypointMux.HandleFunc(readinessProbePath, readyHandler)
+	entrypointMux.Handle("/metrics", metricsHandler())
+	entrypointMux.HandleFunc(statuszPath, statuszHandler)
 
 	go func() {
 		err := http.ListenAndServe(fmt.Sprintf(":%d", livenessProbePort), entrypointMux)
 		if err != nil && err != http.ErrServerClosed {
-			log.Fatal(err)
+			log.Fatalf("entrypoint: fatal: %v", err)
 		}
 	}()
 
-	// Spin up new-subprocess that runs the OTel collector and store the PID.
-	// This OTel collector should use the generated config.
-	var procAttr os.ProcAttr
-	procAttr.Files = []*os.File{nil, /* stdin is not needed for the collector */
-		os.Stdout, os.Stderr}
-	collectorProcess, err := os.StartProcess("./rungmpcol", []string{"./rungmpcol", "--config", otelConfigFile}, &procAttr)
-	if err != nil {
-		log.Fatal(err)
+	// Start the OTel collector with the generated config. A collector
+	// subprocess is restarted if it exits.
+	if err := collector.Start(); err != nil {
+		log.Fatalf("entrypoint: fatal: %v", err)
 	}
 	log.Printf("entrypoint: started OTel successfully")
 
-	refreshTicker := time.NewTicker(configRefreshInterval)
+	changes := source.Watch(ctx)
+
+	// The app is never done unless the sidecar exits with it.
+	var appDone <-chan string
+	var app *appWatcher
+	if exitWithApp {
+		app = newAppWatcher(appDoneFile, appPollInterval)
+		watchAppPorts(app, lastConfig)
+		appDone = app.Watch(ctx)
+	}
+
 	for {
 		select {
-		case <-refreshTicker.C:
-			rawConfig, err := getRawUserConfig(userConfigFile)
+		case <-changes:
+			rawConfig, err := source.Fetch(ctx)
 			if err != nil {
-				log.Fatal(err)
+				log.Printf("entrypoint: error: %v", err)
+				continue
 			}
 
 			// Check if we're using the default config. Only reload if something
-			// has changed since the last time we checked.
+			// has changed since the last time we checked. A rejected config is
+			// remembered too, so that it is only reported once.
 			if rawConfig == lastRawConfig {
 				continue
 			}
+			lastRawConfig = rawConfig
 
-			// Something changed since the last time we checked the config.
-			err = generateOtelConfig(ctx, userConfigFile)
+			c, err := source.Parse(ctx, rawConfig)
 			if err != nil {
-				log.Fatal(err)
+				configReloads.WithLabelValues(reloadFailure).Inc()
+				sidecarStatus.RecordReload(nil, "", err)
+				log.Printf("entrypoint: error: rejected RunMonitoring config, keeping the last good config: %v", err)
+				continue
+			}
+
+			// Formatting or comment-only edits and re-mounts of the same secret
+			// version don't need a collector reload.
+			if reflect.DeepEqual(c, lastConfig) {
+				log.Println("entrypoint: RunMonitoring config unchanged, skipping reload")
+				continue
 			}
-			lastRawConfig = rawConfig
 
-			// Signal the OTel collector to reload its config
-			collectorProcess.Signal(syscall.SIGHUP)
+			// Something changed since the last time we checked the config.
+			otel, err := applyConfig(ctx, c)
+			if err != nil {
+				configReloads.WithLabelValues(reloadFailure).Inc()
+				sidecarStatus.RecordReload(nil, "", err)
+				log.Printf("entrypoint: error: rejected RunMonitoring config, keeping the last good config: %v", err)
+				continue
+			}
+			lastConfig = c
+			expectedTargets.Store(int64(c.ExpectedTargets()))
+			configReloads.WithLabelValues(reloadSuccess).Inc()
+			sidecarStatus.RecordReload(c, otel, nil)
+			watchAppPorts(app, c)
 			log.Println("entrypoint: reloaded OTel config")
+		case <-dumpChan:
+			// Dump our own state first, so that the two dumps don't
+			// interleave in the logs.
+			if err := writeDiagnostics(ctx, os.Stdout); err != nil {
+				log.Printf("entrypoint: error: failed to write diagnostics: %v", err)
+			}
+			if forwardDumpSignal {
+				if err := collector.Signal(diagnostics.Signal); err != nil {
+					log.Printf("entrypoint: error: failed to forward %s to the collector: %v", diagnostics.Signal, err)
+				}
+			}
 		case sig := <-signalChan:
 			// Wait for signals from Cloud Run. Signal the sub process appropriately
 			// after making relevant changes to the config and/or health signals.
-			// TODO(b/307317433): Consider having a timeout to shutdown the subprocess
-			// non-gracefully.
 			log.Printf("entrypoint: %s signal caught", sig)
-
-			collectorProcess.Signal(sig)
-			processState, err := collectorProcess.Wait()
-			if err != nil {
-				log.Fatalf(processState.String(), err)
+			shutdown(ctx, sig)
+			log.Print("entrypoint: sidecar exited")
+			return
+		case reason := <-appDone:
+			// Shutting the collector down makes it scrape all targets one last
+			// time before it flushes.
+			log.Printf("entrypoint: %s, shutting down", reason)
+			if err := shutdown(ctx, syscall.SIGTERM); err != nil {
+				log.Printf("entrypoint: error: sidecar exited, final flush failed: %v", err)
+				os.Exit(1)
 			}
 			log.Print("entrypoint: sidecar exited")
 			return
 		}
 	}
 }
+
+// shutdown stops the collector, killing it if it does not finish its final
+// flush within shutdownTimeout, and reports how far the flush got. It returns
+// an error unless the collector exited cleanly and flushed everything.
+func shutdown(ctx context.Context, sig os.Signal) error {
+	// The collector keeps serving its self metrics while it drains its
+	// queues, so follow them until it is gone.
+	ctx, cancel := context.WithCancel(ctx)
+	followed := make(chan struct{})
+	go func() {
+		defer close(followed)
+		flushes.Follow(ctx)
+	}()
+
+	state := collector.Stop(sig, shutdownTimeout)
+	cancel()
+	<-followed
+	log.Printf("entrypoint: collector stopped, %s", flushes.Report())
+	if state != nil && !state.Success() {
+		return fmt.Errorf("collector %s", describeExit(state))
+	}
+	// The in-process collector has no exit state, so only a collector process
+	// is known to have exited cleanly.
+	return flushes.Flushed(state != nil)
+}
+
+// watchAppPorts makes w watch the metrics ports of the app in c. It is a no-op
+// if w is nil or the app signals that it is done with a file instead.
+func watchAppPorts(w *appWatcher, c *confgenerator.RunMonitoringConfig) {
+	if w == nil || w.doneFile != "" {
+		return
+	}
+	ports, err := c.LocalPorts()
+	if err != nil {
+		log.Printf("entrypoint: warning: failed to find the ports of the app: %v", err)
+		return
+	}
+	if len(ports) == 0 {
+		log.Print("entrypoint: warning: no endpoint is scraped on the app's own ports, set --app-done-file to exit with the app")
+	}
+	w.SetPorts(ports)
+}
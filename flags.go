@@ -0,0 +1,81 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// Prefix of the environment variables that override the entrypoint's flags.
+const envPrefix = "RUN_GMP_"
+
+// Print the generated OTel config and exit instead of running the collector.
+var dryRun = false
+
+// Run the collector inside the entrypoint process instead of as a subprocess.
+var inProcess = false
+
+// registerFlags registers the entrypoint's settings on fs. The current values
+// of the settings are used as defaults.
+func registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&userConfigFile, "user-config", userConfigFile, "Path to the RunMonitoring config file or directory, or an http(s) URL to poll it from.")
+	fs.StringVar(&otelConfigFile, "otel-config", otelConfigFile, "Path to write the generated OTel collector config to.")
+	fs.DurationVar(&configRefreshInterval, "config-refresh-interval", configRefreshInterval, "How often to re-read the RunMonitoring config file in addition to watching it for changes.")
+	fs.IntVar(&selfMetricsPort, "self-metrics-port", selfMetricsPort, "Port on which the collector serves its self metrics. A free port is picked if 0.")
+	fs.IntVar(&livenessProbePort, "liveness-probe-port", livenessProbePort, "Port on which the entrypoint serves its probes and metrics.")
+	fs.StringVar(&livenessProbePath, "liveness-probe-path", livenessProbePath, "HTTP path of the liveness probe.")
+	fs.StringVar(&startupProbePath, "startup-probe-path", startupProbePath, "HTTP path of the startup probe.")
+	fs.StringVar(&readinessProbePath, "readiness-probe-path", readinessProbePath, "HTTP path of the readiness probe.")
+	fs.DurationVar(&delayLivenessProbe, "liveness-probe-delay", delayLivenessProbe, "Maximum time the liveness probe waits for the collector to flush.")
+	fs.DurationVar(&shutdownTimeout, "shutdown-timeout", shutdownTimeout, "Time the collector is given to shut down before it is killed.")
+	fs.BoolVar(&inProcess, "in-process", inProcess, "Run the OTel collector inside the entrypoint process instead of as a subprocess.")
+	fs.StringVar(&logFormat, "log-format", logFormat, `Format of the entrypoint and collector logs: "text", or "json" for Cloud Logging structured logs.`)
+	fs.BoolVar(&exitWithApp, "exit-with-app", exitWithApp, "Shut down and exit once the app is done, for run-to-completion containers such as Cloud Run jobs. The exit status tells whether the final flush succeeded.")
+	fs.StringVar(&appDoneFile, "app-done-file", appDoneFile, "With --exit-with-app, path of the file the app creates when it is done, e.g. on a shared in-memory volume. If empty, the app is done once it stops listening on its metrics ports.")
+	fs.DurationVar(&appPollInterval, "app-poll-interval", appPollInterval, "With --exit-with-app, how often to check whether the app is done.")
+	fs.BoolVar(&forwardDumpSignal, "forward-dump-signal", forwardDumpSignal, "Forward the diagnostic dump signal to the collector subprocess. Only set it for a collector built from collector/cmd/rungmpcol, the signal terminates other builds.")
+	fs.BoolVar(&dryRun, "dry-run", dryRun, "Print the OTel config generated from the RunMonitoring config and exit.")
+
+	fs.VisitAll(func(f *flag.Flag) {
+		f.Usage += fmt.Sprintf(" Can also be set with %s.", envName(f.Name))
+	})
+}
+
+// envName returns the environment variable that overrides the flag called
+// name, e.g. RUN_GMP_USER_CONFIG for --user-config.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnvOverrides sets the flags of fs from their environment variables.
+// It must be called before fs is parsed, so that flags given on the command
+// line take precedence over the environment.
+func applyEnvOverrides(fs *flag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		name := envName(f.Name)
+		if v, ok := lookupEnv(name); ok {
+			if setErr := f.Value.Set(v); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", v, name, setErr)
+			}
+		}
+	})
+	return err
+}
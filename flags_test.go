@@ -0,0 +1,84 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restoreSettings resets the settings changed by a test to their current
+// values once the test is done.
+func restoreSettings(t *testing.T) {
+	t.Helper()
+	u, o, r, p, d := userConfigFile, otelConfigFile, configRefreshInterval, livenessProbePort, dryRun
+	t.Cleanup(func() {
+		userConfigFile, otelConfigFile, configRefreshInterval, livenessProbePort, dryRun = u, o, r, p, d
+	})
+}
+
+func newTestFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	registerFlags(fs)
+	return fs
+}
+
+func TestEnvOverridesAndFlagPrecedence(t *testing.T) {
+	restoreSettings(t)
+	fs := newTestFlagSet()
+
+	env := map[string]string{
+		"RUN_GMP_USER_CONFIG":             "/env/config.yaml",
+		"RUN_GMP_OTEL_CONFIG":             "/env/otel.yaml",
+		"RUN_GMP_CONFIG_REFRESH_INTERVAL": "1m",
+		"RUN_GMP_DRY_RUN":                 "true",
+	}
+	require.NoError(t, applyEnvOverrides(fs, func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}))
+	require.NoError(t, fs.Parse([]string{"--otel-config=/flag/otel.yaml", "--liveness-probe-port=8888"}))
+
+	assert.Equal(t, "/env/config.yaml", userConfigFile)
+	assert.Equal(t, "/flag/otel.yaml", otelConfigFile)
+	assert.Equal(t, time.Minute, configRefreshInterval)
+	assert.Equal(t, 8888, livenessProbePort)
+	assert.True(t, dryRun)
+}
+
+func TestInvalidEnvOverride(t *testing.T) {
+	restoreSettings(t)
+	fs := newTestFlagSet()
+
+	err := applyEnvOverrides(fs, func(k string) (string, bool) {
+		if k == "RUN_GMP_CONFIG_REFRESH_INTERVAL" {
+			return "often", true
+		}
+		return "", false
+	})
+	assert.ErrorContains(t, err, "RUN_GMP_CONFIG_REFRESH_INTERVAL")
+}
+
+func TestFlagUsageMentionsEnv(t *testing.T) {
+	restoreSettings(t)
+	fs := newTestFlagSet()
+	assert.Contains(t, fs.Lookup("self-metrics-port").Usage, "RUN_GMP_SELF_METRICS_PORT")
+}
@@ -0,0 +1,247 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Self metrics of the collector that tell whether telemetry is still being
+// exported. Counters may or may not carry a _total suffix depending on the
+// collector version, so both spellings are accepted.
+const (
+	exporterQueueSizeMetric        = "otelcol_exporter_queue_size"
+	exporterSentPointsMetric       = "otelcol_exporter_sent_metric_points"
+	exporterSendFailedPointsMetric = "otelcol_exporter_send_failed_metric_points"
+	grpcAttemptStartedMetric       = "grpc_client_attempt_started"
+	grpcAttemptDurationMetric      = "grpc_client_attempt_duration"
+)
+
+// flushState is a snapshot of the collector's export progress.
+type flushState struct {
+	// Number of batches waiting in the exporter sending queues. The collector
+	// reports its queue size in export requests, not points, and has no
+	// count of the points in them.
+	queued float64
+	// Number of export RPCs that have started but not completed yet.
+	inFlight float64
+	// Number of points the exporters have sent and failed to send.
+	sent   float64
+	failed float64
+}
+
+// exported returns the number of points the exporters are done with,
+// successfully or not. It only ever grows while the exporters are making
+// progress.
+func (s flushState) exported() float64 {
+	return s.sent + s.failed
+}
+
+func (s flushState) drained() bool {
+	return s.queued <= 0 && s.inFlight <= 0
+}
+
+// readFlushState scrapes the collector's self metrics endpoint at url.
+func readFlushState(ctx context.Context, client *http.Client, url string) (flushState, error) {
+	families, err := scrapeSelfMetrics(ctx, client, url)
+	if err != nil {
+		return flushState{}, err
+	}
+	return flushStateOf(families), nil
+}
+
+// flushStateOf returns the export progress reported by the self metrics
+// families.
+func flushStateOf(families map[string]*dto.MetricFamily) flushState {
+	var s flushState
+	for name, family := range families {
+		switch strings.TrimSuffix(name, "_total") {
+		case exporterQueueSizeMetric:
+			s.queued += sumValues(family)
+		case exporterSentPointsMetric:
+			s.sent += sumValues(family)
+		case exporterSendFailedPointsMetric:
+			s.failed += sumValues(family)
+		case grpcAttemptStartedMetric:
+			s.inFlight += sumValues(family)
+		case grpcAttemptDurationMetric:
+			s.inFlight -= sumValues(family)
+		}
+	}
+	return s
+}
+
+// flushWatcher waits for the collector to flush its pending telemetry and
+// keeps track of whether the exporters are making progress at all.
+type flushWatcher struct {
+	client *http.Client
+	// url returns the address of the collector's self metrics endpoint. It is
+	// a function because the port is only picked once the config is generated.
+	url          func() string
+	pollInterval time.Duration
+	// The exporters are considered wedged if they have pending work but have
+	// not finished exporting a single point for stallTimeout.
+	stallTimeout time.Duration
+
+	mu           sync.Mutex
+	observed     bool
+	last         flushState
+	lastProgress time.Time
+	// Outcome of the most recent export, if any was observed.
+	exportOutcomeKnown  bool
+	lastExportSucceeded bool
+}
+
+func newFlushWatcher(url func() string, stallTimeout time.Duration) *flushWatcher {
+	return &flushWatcher{
+		client:       selfMetricsClient,
+		url:          url,
+		pollInterval: 100 * time.Millisecond,
+		stallTimeout: stallTimeout,
+	}
+}
+
+// Wait polls the collector until it has no queued or in-flight exports, or
+// until maxWait has passed. It returns an error if the exporters still have
+// pending work and have not made progress for stallTimeout.
+//
+// If the self metrics cannot be read, e.g. while the collector is starting,
+// Wait falls back to holding on for maxWait so the collector gets CPU time
+// regardless.
+func (f *flushWatcher) Wait(ctx context.Context, maxWait time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+
+	var last flushState
+	var lastErr error
+	for {
+		s, err := readFlushState(ctx, f.client, f.url())
+		if err == nil {
+			last, lastErr = s, nil
+			f.observe(s)
+			if s.drained() {
+				return nil
+			}
+		} else if ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return nil
+			}
+			return f.check(last)
+		case <-ticker.C:
+		}
+	}
+}
+
+// observe records export progress.
+func (f *flushWatcher) observe(s flushState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// A restarted collector starts counting from zero again, which counts as
+	// progress too.
+	if !f.observed || s.exported() != f.last.exported() {
+		f.lastProgress = time.Now()
+	}
+	if f.observed {
+		switch {
+		case s.failed > f.last.failed:
+			f.exportOutcomeKnown, f.lastExportSucceeded = true, false
+		case s.sent > f.last.sent:
+			f.exportOutcomeKnown, f.lastExportSucceeded = true, true
+		}
+	}
+	f.observed = true
+	f.last = s
+}
+
+// Follow keeps recording the collector's export progress until ctx is
+// cancelled. It is used during shutdown, while the collector drains its
+// queues, so that Report can tell how far it got.
+func (f *flushWatcher) Follow(ctx context.Context) {
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+	for {
+		if s, err := readFlushState(ctx, f.client, f.url()); err == nil {
+			f.observe(s)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report describes the last observed export state of the collector.
+func (f *flushWatcher) Report() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.observed {
+		return "collector self metrics were never read, flush status is unknown"
+	}
+	outcome := "unknown"
+	if f.exportOutcomeKnown {
+		outcome = fmt.Sprintf("%t", f.lastExportSucceeded)
+	}
+	return fmt.Sprintf("%v batches were still queued, last export succeeded: %s", f.last.queued, outcome)
+}
+
+// Flushed returns an error unless the collector is done exporting without a
+// failed export. That is the case if it was last seen with nothing left to
+// export, or if it exited cleanly, since the collector drains its queues
+// before it exits. The last observation is usually from the middle of that
+// drain then.
+func (f *flushWatcher) Flushed(exitedCleanly bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case !f.observed:
+		return fmt.Errorf("collector self metrics were never read, flush status is unknown")
+	case f.exportOutcomeKnown && !f.lastExportSucceeded:
+		return fmt.Errorf("last export failed")
+	case !exitedCleanly && !f.last.drained():
+		return fmt.Errorf("%v batches were still queued and %v sends in flight", f.last.queued, f.last.inFlight)
+	}
+	return nil
+}
+
+// check returns an error if the exporters have pending work that has not
+// moved for stallTimeout.
+func (f *flushWatcher) check(s flushState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s.drained() {
+		return nil
+	}
+	if stalled := time.Since(f.lastProgress); stalled >= f.stallTimeout {
+		return fmt.Errorf("exporter is wedged: %v batches queued and %v sends in flight without progress for %v",
+			s.queued, s.inFlight, stalled.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selfMetrics(queued, started, completed, sent int) string {
+	return fmt.Sprintf(`# TYPE otelcol_exporter_queue_size gauge
+otelcol_exporter_queue_size{exporter="googlemanagedprometheus"} %d
+# TYPE otelcol_exporter_sent_metric_points_total counter
+otelcol_exporter_sent_metric_points_total{exporter="googlemanagedprometheus"} %d
+# TYPE otelcol_exporter_send_failed_metric_points_total counter
+otelcol_exporter_send_failed_metric_points_total{exporter="googlemanagedprometheus"} 0
+# TYPE grpc_client_attempt_started_total counter
+grpc_client_attempt_started_total{grpc_method="google.monitoring.v3.MetricService/CreateTimeSeries"} %d
+# TYPE grpc_client_attempt_duration histogram
+grpc_client_attempt_duration_bucket{grpc_method="google.monitoring.v3.MetricService/CreateTimeSeries",le="+Inf"} %d
+grpc_client_attempt_duration_sum{grpc_method="google.monitoring.v3.MetricService/CreateTimeSeries"} 1
+grpc_client_attempt_duration_count{grpc_method="google.monitoring.v3.MetricService/CreateTimeSeries"} %d
+`, queued, sent, started, completed, completed)
+}
+
+func TestReadFlushState(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, selfMetrics(3, 10, 8, 500))
+	}))
+	defer srv.Close()
+
+	s, err := readFlushState(context.Background(), srv.Client(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, flushState{queued: 3, inFlight: 2, sent: 500}, s)
+	assert.False(t, s.drained())
+}
+
+func TestFlushWatcherReturnsWhenDrained(t *testing.T) {
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// The queue drains on the third poll.
+		if polls.Add(1) < 3 {
+			fmt.Fprint(w, selfMetrics(1, 5, 4, 100))
+			return
+		}
+		fmt.Fprint(w, selfMetrics(0, 5, 5, 200))
+	}))
+	defer srv.Close()
+
+	f := newFlushWatcher(func() string { return srv.URL }, time.Minute)
+	f.pollInterval = 10 * time.Millisecond
+
+	start := time.Now()
+	require.NoError(t, f.Wait(context.Background(), 10*time.Second))
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.EqualValues(t, 3, polls.Load())
+}
+
+func TestFlushWatcherDetectsWedgedExporter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, selfMetrics(7, 1, 0, 100))
+	}))
+	defer srv.Close()
+
+	f := newFlushWatcher(func() string { return srv.URL }, 50*time.Millisecond)
+	f.pollInterval = 10 * time.Millisecond
+
+	// The first probe only establishes the baseline.
+	require.NoError(t, f.Wait(context.Background(), 20*time.Millisecond))
+	time.Sleep(50 * time.Millisecond)
+	assert.ErrorContains(t, f.Wait(context.Background(), 20*time.Millisecond), "wedged")
+}
+
+func TestFlushWatcherToleratesMissingSelfMetrics(t *testing.T) {
+	f := newFlushWatcher(func() string { return "http://127.0.0.1:1/metrics" }, 0)
+	f.pollInterval = 10 * time.Millisecond
+
+	start := time.Now()
+	require.NoError(t, f.Wait(context.Background(), 50*time.Millisecond))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestFlushWatcherReport(t *testing.T) {
+	f := newFlushWatcher(func() string { return "" }, time.Minute)
+	assert.Contains(t, f.Report(), "unknown")
+
+	f.observe(flushState{queued: 2, sent: 100})
+	assert.Equal(t, "2 batches were still queued, last export succeeded: unknown", f.Report())
+	f.observe(flushState{queued: 1, sent: 150})
+	assert.Equal(t, "1 batches were still queued, last export succeeded: true", f.Report())
+	f.observe(flushState{queued: 1, sent: 150, failed: 20})
+	assert.Equal(t, "1 batches were still queued, last export succeeded: false", f.Report())
+	// No exports in between keep the previous outcome.
+	f.observe(flushState{queued: 0, sent: 150, failed: 20})
+	assert.Equal(t, "0 batches were still queued, last export succeeded: false", f.Report())
+}
+
+func TestFlushWatcherFlushed(t *testing.T) {
+	f := newFlushWatcher(func() string { return "" }, time.Minute)
+	assert.ErrorContains(t, f.Flushed(false), "unknown")
+	assert.ErrorContains(t, f.Flushed(true), "unknown")
+
+	f.observe(flushState{queued: 2, sent: 100})
+	assert.ErrorContains(t, f.Flushed(false), "2 batches were still queued")
+	f.observe(flushState{sent: 150, inFlight: 1})
+	assert.ErrorContains(t, f.Flushed(false), "1 sends in flight")
+	// The collector drains its queues before it exits.
+	assert.NoError(t, f.Flushed(true))
+	f.observe(flushState{sent: 200})
+	assert.NoError(t, f.Flushed(false))
+	f.observe(flushState{sent: 200, failed: 20})
+	assert.ErrorContains(t, f.Flushed(false), "last export failed")
+	assert.ErrorContains(t, f.Flushed(true), "last export failed")
+}
+
+func TestShutdownSucceedsWhenCollectorExitsMidDrain(t *testing.T) {
+	// The self metrics are never seen drained, the collector exits before.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, selfMetrics(2, 10, 9, 500))
+	}))
+	defer srv.Close()
+
+	defer func(c otelCollector, f *flushWatcher) { collector, flushes = c, f }(collector, flushes)
+	// The shell exits with code 0 a moment after SIGTERM, like a collector
+	// that finishes draining its queues.
+	collector = &subprocessCollector{
+		collectorSupervisor: newCollectorSupervisor(lookPath(t, "sh"), "-c", "trap 'sleep 0.3; exit 0' TERM; while :; do sleep 0.01; done"),
+	}
+	flushes = newFlushWatcher(func() string { return srv.URL }, time.Minute)
+	flushes.client = srv.Client()
+	require.NoError(t, collector.Start())
+	// Give the shell a moment to install its trap.
+	time.Sleep(100 * time.Millisecond)
+
+	assert.NoError(t, shutdown(context.Background(), syscall.SIGTERM))
+	assert.Equal(t, "2 batches were still queued, last export succeeded: unknown", flushes.Report())
+}
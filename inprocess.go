@@ -0,0 +1,132 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/collector/provider/memprovider"
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/collector/service"
+	"go.opentelemetry.io/collector/otelcol"
+)
+
+// inProcessCollector runs the collector inside the entrypoint process, which
+// saves the memory of a second Go runtime. The config is handed over in
+// memory, and reloads go through the collector's config watch.
+//
+// Unlike a subprocess, a collector that failed can't be restarted. It is
+// reported as unhealthy instead, so that Cloud Run restarts the container.
+type inProcessCollector struct {
+	provider *memprovider.Provider
+
+	mu        sync.Mutex
+	col       *otelcol.Collector
+	startedAt time.Time
+	exited    bool
+	stopping  bool
+
+	done chan struct{}
+}
+
+func newInProcessCollector() *inProcessCollector {
+	return &inProcessCollector{
+		provider: memprovider.New(),
+		done:     make(chan struct{}),
+	}
+}
+
+func (c *inProcessCollector) Configure(otel string) error {
+	c.provider.Set([]byte(otel))
+	return nil
+}
+
+func (c *inProcessCollector) Start() error {
+	col, err := service.NewCollector(c.provider.URI(), c.provider.NewFactory())
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.col = col
+	c.startedAt = time.Now()
+	c.mu.Unlock()
+
+	go func() {
+		defer close(c.done)
+		err := col.Run(context.Background())
+
+		c.mu.Lock()
+		c.exited = true
+		stopping := c.stopping
+		c.mu.Unlock()
+		if err != nil {
+			log.Printf("entrypoint: error: collector failed: %v", err)
+		} else if !stopping {
+			log.Printf("entrypoint: error: collector exited unexpectedly")
+		}
+	}()
+	return nil
+}
+
+func (c *inProcessCollector) Healthy() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.exited || c.stopping
+}
+
+func (c *inProcessCollector) Running() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.col != nil && !c.exited && c.col.GetState() == otelcol.StateRunning
+}
+
+func (c *inProcessCollector) Process() (pid int, startedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.col == nil || c.exited {
+		return 0, time.Time{}
+	}
+	return os.Getpid(), c.startedAt
+}
+
+// Signal does nothing: signals are handled by the entrypoint, and its
+// diagnostic dump already covers the collector.
+func (c *inProcessCollector) Signal(os.Signal) error {
+	return nil
+}
+
+// Stop shuts the collector down. The collector does not handle signals, so
+// sig is not used. A collector can't be killed either: if it does not shut
+// down within timeout, the entrypoint exits without waiting for it.
+func (c *inProcessCollector) Stop(_ os.Signal, timeout time.Duration) *os.ProcessState {
+	c.mu.Lock()
+	c.stopping = true
+	col := c.col
+	c.mu.Unlock()
+	if col == nil {
+		return nil
+	}
+
+	col.Shutdown()
+	select {
+	case <-c.done:
+	case <-time.After(timeout):
+		log.Printf("entrypoint: warning: collector did not shut down within %v", timeout)
+	}
+	return nil
+}
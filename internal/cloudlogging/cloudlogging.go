@@ -0,0 +1,176 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudlogging writes logs in the structured JSON format understood
+// by Cloud Logging, so that the entrypoint and the collector logs show up with
+// the right severity and source location.
+//
+// See https://cloud.google.com/logging/docs/structured-logging.
+package cloudlogging
+
+import (
+	"os"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// FormatEnv is the environment variable that selects the log format of
+	// both the entrypoint and the collector. The collector inherits it from
+	// the entrypoint.
+	FormatEnv = "RUN_GMP_LOG_FORMAT"
+
+	// FormatText is the default format of each process: the standard
+	// library's log lines for the entrypoint and zap's console encoder for
+	// the collector.
+	FormatText = "text"
+	// FormatJSON is Cloud Logging structured JSON.
+	FormatJSON = "json"
+)
+
+// Special fields of structured log entries.
+const (
+	severityKey       = "severity"
+	messageKey        = "message"
+	timeKey           = "time"
+	labelsKey         = "logging.googleapis.com/labels"
+	sourceLocationKey = "logging.googleapis.com/sourceLocation"
+)
+
+// Enabled reports whether the JSON format was selected with FormatEnv.
+func Enabled() bool {
+	return os.Getenv(FormatEnv) == FormatJSON
+}
+
+// Labels returns the labels that are attached to every entry logged by
+// process: the process name and the Cloud Run service and revision, or the
+// job, execution and task index.
+func Labels(process string) map[string]string {
+	labels := map[string]string{"process": process}
+	for label, env := range map[string]string{
+		"service_name":   "K_SERVICE",
+		"revision_name":  "K_REVISION",
+		"job_name":       "CLOUD_RUN_JOB",
+		"execution_name": "CLOUD_RUN_EXECUTION",
+		"task_index":     "CLOUD_RUN_TASK_INDEX",
+	} {
+		if value := os.Getenv(env); value != "" {
+			labels[label] = value
+		}
+	}
+	return labels
+}
+
+// NewCore returns a core that writes the entries enabled by level to w as
+// structured JSON, with the given labels.
+func NewCore(w zapcore.WriteSyncer, level zapcore.LevelEnabler, labels map[string]string) zapcore.Core {
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		MessageKey:     messageKey,
+		LevelKey:       severityKey,
+		TimeKey:        timeKey,
+		NameKey:        "logger",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    encodeSeverity,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	})
+	return &core{
+		Core:   zapcore.NewCore(encoder, w, level),
+		labels: labels,
+	}
+}
+
+// Option returns a zap option that makes a logger write structured JSON to
+// stderr. The level of the logger is kept, but its encoder and output are
+// replaced, so it should be the first of the logger's options.
+func Option(process string) zap.Option {
+	labels := Labels(process)
+	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return NewCore(zapcore.Lock(os.Stderr), c, labels)
+	})
+}
+
+// NewLogger returns a logger for process that writes structured JSON to
+// stderr at info level and above.
+func NewLogger(process string) *zap.Logger {
+	return zap.New(NewCore(zapcore.Lock(os.Stderr), zapcore.InfoLevel, Labels(process)), zap.AddCaller())
+}
+
+// core adds the labels and the source location of every entry. The caller is
+// not encoded by the JSON encoder, since Cloud Logging expects an object
+// rather than a string.
+type core struct {
+	zapcore.Core
+	labels map[string]string
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{Core: c.Core.With(fields), labels: c.labels}
+}
+
+func (c *core) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	// Don't append to the caller's slice.
+	fields = fields[:len(fields):len(fields)]
+	fields = append(fields, zap.Object(labelsKey, zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		for k, v := range c.labels {
+			enc.AddString(k, v)
+		}
+		return nil
+	})))
+	if entry.Caller.Defined {
+		fields = append(fields, zap.Object(sourceLocationKey, zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("file", entry.Caller.File)
+			enc.AddString("line", strconv.Itoa(entry.Caller.Line))
+			enc.AddString("function", entry.Caller.Function)
+			return nil
+		})))
+	}
+	return c.Core.Write(entry, fields)
+}
+
+// encodeSeverity maps zap levels to Cloud Logging severities.
+func encodeSeverity(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(severity(l))
+}
+
+func severity(l zapcore.Level) string {
+	switch l {
+	case zapcore.DebugLevel:
+		return "DEBUG"
+	case zapcore.InfoLevel:
+		return "INFO"
+	case zapcore.WarnLevel:
+		return "WARNING"
+	case zapcore.ErrorLevel:
+		return "ERROR"
+	case zapcore.DPanicLevel:
+		return "CRITICAL"
+	case zapcore.PanicLevel:
+		return "ALERT"
+	case zapcore.FatalLevel:
+		return "EMERGENCY"
+	default:
+		return "DEFAULT"
+	}
+}
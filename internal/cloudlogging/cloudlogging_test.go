@@ -0,0 +1,169 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func decodeEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestCoreWritesStructuredEntries(t *testing.T) {
+	t.Setenv("K_SERVICE", "my-service")
+	t.Setenv("K_REVISION", "my-service-00001")
+
+	var buf bytes.Buffer
+	logger := zap.New(NewCore(zapcore.AddSync(&buf), zapcore.InfoLevel, Labels("collector")), zap.AddCaller())
+	logger.With(zap.String("kind", "receiver")).Warn("scrape failed", zap.Int("attempt", 2))
+	logger.Debug("not logged")
+
+	entries := decodeEntries(t, &buf)
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, "WARNING", entry["severity"])
+	assert.Equal(t, "scrape failed", entry["message"])
+	assert.Equal(t, "receiver", entry["kind"])
+	assert.EqualValues(t, 2, entry["attempt"])
+	assert.NotEmpty(t, entry["time"])
+	assert.Equal(t, map[string]any{
+		"process":       "collector",
+		"service_name":  "my-service",
+		"revision_name": "my-service-00001",
+	}, entry["logging.googleapis.com/labels"])
+
+	source, ok := entry["logging.googleapis.com/sourceLocation"].(map[string]any)
+	require.True(t, ok, "missing source location in %v", entry)
+	assert.Contains(t, source["file"], "cloudlogging_test.go")
+	assert.NotEmpty(t, source["line"])
+	assert.Contains(t, source["function"], "TestCoreWritesStructuredEntries")
+}
+
+func TestLabelsInJobs(t *testing.T) {
+	t.Setenv("CLOUD_RUN_JOB", "my-job")
+	t.Setenv("CLOUD_RUN_EXECUTION", "my-job-abc12")
+	t.Setenv("CLOUD_RUN_TASK_INDEX", "3")
+
+	assert.Equal(t, map[string]string{
+		"process":        "entrypoint",
+		"job_name":       "my-job",
+		"execution_name": "my-job-abc12",
+		"task_index":     "3",
+	}, Labels("entrypoint"))
+}
+
+func TestCoreKeepsWrappedLevel(t *testing.T) {
+	var buf bytes.Buffer
+	base := zapcore.NewCore(zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()), zapcore.AddSync(&buf), zapcore.ErrorLevel)
+	// Like Option, but without writing to stderr.
+	logger := zap.New(base, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return NewCore(zapcore.AddSync(&buf), c, Labels("collector"))
+	}))
+	logger.Info("not logged")
+	logger.Error("logged")
+
+	entries := decodeEntries(t, &buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "ERROR", entries[0]["severity"])
+}
+
+func TestSeverity(t *testing.T) {
+	for level, want := range map[zapcore.Level]string{
+		zapcore.DebugLevel:  "DEBUG",
+		zapcore.InfoLevel:   "INFO",
+		zapcore.WarnLevel:   "WARNING",
+		zapcore.ErrorLevel:  "ERROR",
+		zapcore.DPanicLevel: "CRITICAL",
+		zapcore.PanicLevel:  "ALERT",
+		zapcore.FatalLevel:  "EMERGENCY",
+	} {
+		assert.Equal(t, want, severity(level))
+	}
+}
+
+func TestLevel(t *testing.T) {
+	for msg, want := range map[string]zapcore.Level{
+		"entrypoint: started OTel successfully":                 zapcore.InfoLevel,
+		"entrypoint: warning: no endpoint is scraped":           zapcore.WarnLevel,
+		"entrypoint: error: rejected RunMonitoring config":      zapcore.ErrorLevel,
+		"entrypoint: fatal: invalid config":                     zapcore.DPanicLevel,
+		"error: failed to build environment variables":          zapcore.ErrorLevel,
+		"entrypoint: collector exited with error: status 1":     zapcore.InfoLevel,
+		"confgenerator: using RunMonitoring config:\nwarning: ": zapcore.InfoLevel,
+	} {
+		assert.Equal(t, want, Level(msg), msg)
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	var buf bytes.Buffer
+	restore := RedirectStdLog(zap.New(NewCore(zapcore.AddSync(&buf), zapcore.InfoLevel, Labels("entrypoint")), zap.AddCaller()))
+	defer restore()
+	log.Printf("entrypoint: error: failed to write %s", "/statusz")
+	log.Print("entrypoint: started")
+
+	entries := decodeEntries(t, &buf)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "ERROR", entries[0]["severity"])
+	assert.Equal(t, "entrypoint: error: failed to write /statusz", entries[0]["message"])
+	assert.Equal(t, "INFO", entries[1]["severity"])
+
+	source, ok := entries[0]["logging.googleapis.com/sourceLocation"].(map[string]any)
+	require.True(t, ok, "missing source location in %v", entries[0])
+	assert.Contains(t, source["file"], "cloudlogging_test.go")
+	assert.Contains(t, source["function"], "TestRedirectStdLog")
+}
+
+func TestCopyZapJSON(t *testing.T) {
+	in := strings.Join([]string{
+		`{"level":"error","ts":1767225600.5,"caller":"scrape/scrape.go:42","msg":"scrape failed","kind":"receiver"}`,
+		`{"severity":"INFO","message":"already structured"}`,
+		`panic: boom`,
+	}, "\n") + "\n"
+	var buf bytes.Buffer
+	require.NoError(t, CopyZapJSON(&buf, strings.NewReader(in), map[string]string{"process": "collector"}))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, map[string]any{
+		"severity":                              "ERROR",
+		"message":                               "scrape failed",
+		"time":                                  "2026-01-01T00:00:00.5Z",
+		"kind":                                  "receiver",
+		"logging.googleapis.com/labels":         map[string]any{"process": "collector"},
+		"logging.googleapis.com/sourceLocation": map[string]any{"file": "scrape/scrape.go", "line": "42"},
+	}, entry)
+	assert.Equal(t, `{"severity":"INFO","message":"already structured"}`, lines[1])
+	assert.Equal(t, "panic: boom", lines[2])
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"log"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Markers that set the severity of a standard library log message. They
+// follow the name of the component that logs the message, if any, e.g.
+// "entrypoint: error: failed to write /statusz". Messages without a marker
+// are logged at info level.
+const (
+	WarningMarker = "warning: "
+	ErrorMarker   = "error: "
+	// FatalMarker is for the messages logged with log.Fatal.
+	FatalMarker = "fatal: "
+)
+
+// Frames between the caller of the standard library's logger and the writer's
+// call to Check: log.Printf, log.(*Logger).output and stdLogWriter.Write.
+const stdLogCallerSkip = 3
+
+// RedirectStdLog sends the output of the standard library's global logger to
+// l, at the level given by each message's marker. It returns a function that
+// restores the original output.
+func RedirectStdLog(l *zap.Logger) func() {
+	flags, prefix, output := log.Flags(), log.Prefix(), log.Writer()
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(&stdLogWriter{logger: l.WithOptions(zap.AddCallerSkip(stdLogCallerSkip))})
+	return func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(output)
+	}
+}
+
+// Level returns the level of a standard library log message, see the markers.
+// Only the first line of the message is considered, the rest may well be a
+// config that happens to contain a marker.
+func Level(msg string) zapcore.Level {
+	msg, _, _ = strings.Cut(msg, "\n")
+	// Skip the component name, which does not contain spaces.
+	if name, rest, ok := strings.Cut(msg, ": "); ok && !strings.Contains(name, " ") {
+		if l, ok := markerLevel(rest); ok {
+			return l
+		}
+	}
+	if l, ok := markerLevel(msg); ok {
+		return l
+	}
+	return zapcore.InfoLevel
+}
+
+func markerLevel(msg string) (zapcore.Level, bool) {
+	switch {
+	case strings.HasPrefix(msg, WarningMarker):
+		return zapcore.WarnLevel, true
+	case strings.HasPrefix(msg, ErrorMarker):
+		return zapcore.ErrorLevel, true
+	case strings.HasPrefix(msg, FatalMarker):
+		// DPanic is logged as CRITICAL. It does not panic unless the
+		// logger is in development mode, and the caller exits anyway.
+		return zapcore.DPanicLevel, true
+	}
+	return zapcore.InfoLevel, false
+}
+
+type stdLogWriter struct {
+	logger *zap.Logger
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	if ce := w.logger.Check(Level(msg), msg); ce != nil {
+		ce.Write()
+	}
+	return len(p), nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudlogging
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Keys of zap's production JSON encoder, which the collector uses when its
+// logs are configured with encoding json.
+const (
+	zapLevelKey   = "level"
+	zapTimeKey    = "ts"
+	zapMessageKey = "msg"
+	zapCallerKey  = "caller"
+)
+
+// ZapJSONFlag makes a collector log zap's production JSON, which CopyZapJSON
+// converts. It is for collectors that don't use Option, such as the one built
+// from the distribution manifest.
+const ZapJSONFlag = "--set=service::telemetry::logs::encoding=json"
+
+// CopyZapJSON copies the logs of a process that writes zap's production JSON
+// from r to w, converting each entry to structured JSON with the given labels.
+// Lines that are not such entries, e.g. a panic's stack trace or an entry
+// that is already structured, are copied as they are. It returns once r is
+// exhausted.
+func CopyZapJSON(w io.Writer, r io.Reader, labels map[string]string) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			line = convertZapJSON(line, labels)
+			if _, werr := w.Write(line); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// convertZapJSON converts a single line of zap's production JSON. It returns
+// line as is if it is not an entry of that format.
+func convertZapJSON(line []byte, labels map[string]string) []byte {
+	var entry map[string]json.RawMessage
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return line
+	}
+	var level string
+	if err := json.Unmarshal(entry[zapLevelKey], &level); err != nil {
+		return line
+	}
+	l, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return line
+	}
+
+	out := make(map[string]any, len(entry)+2)
+	for k, v := range entry {
+		out[k] = v
+	}
+	delete(out, zapLevelKey)
+	out[severityKey] = severity(l)
+	if msg, ok := entry[zapMessageKey]; ok {
+		delete(out, zapMessageKey)
+		out[messageKey] = msg
+	}
+	// The production encoder writes the time as seconds since the epoch.
+	var ts float64
+	if err := json.Unmarshal(entry[zapTimeKey], &ts); err == nil {
+		sec, frac := math.Modf(ts)
+		delete(out, zapTimeKey)
+		out[timeKey] = time.Unix(int64(sec), int64(frac*1e9)).UTC().Format(time.RFC3339Nano)
+	}
+	var caller string
+	if err := json.Unmarshal(entry[zapCallerKey], &caller); err == nil {
+		if file, line, ok := strings.Cut(caller, ":"); ok {
+			delete(out, zapCallerKey)
+			out[sourceLocationKey] = map[string]string{"file": file, "line": line}
+		}
+	}
+	out[labelsKey] = labels
+
+	converted, err := json.Marshal(out)
+	if err != nil {
+		return line
+	}
+	return append(converted, '\n')
+}
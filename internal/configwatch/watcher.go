@@ -0,0 +1,221 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configwatch watches RunMonitoring config files and directories for
+// changes. It is shared by the entrypoint and the collector's rungmp config
+// provider.
+package configwatch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/confgenerator"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher notifies on its Changes channel whenever the user config file
+// may have changed. Notifications are only hints: receivers are expected to
+// re-read the file and decide for themselves whether anything is different.
+type Watcher struct {
+	path         string
+	debounce     time.Duration
+	pollInterval time.Duration
+
+	// Changes receives a value after a burst of file system events has settled,
+	// and on every poll tick.
+	Changes chan struct{}
+}
+
+// New returns a watcher for the config file or directory at path. Bursts of
+// file system events are debounced, and the path is also polled every
+// pollInterval.
+func New(path string, debounce, pollInterval time.Duration) *Watcher {
+	return &Watcher{
+		path:         path,
+		debounce:     debounce,
+		pollInterval: pollInterval,
+		// Buffer a single notification so that a burst of changes while the
+		// receiver is busy collapses into one reload.
+		Changes: make(chan struct{}, 1),
+	}
+}
+
+// Run watches the config file until ctx is cancelled.
+//
+// Secret Manager volumes are updated by writing the new version next to the
+// old one and atomically swapping a symlink. A watch on the file itself would
+// keep pointing at the old inode, so we watch the parent directory instead
+// and treat any event in it as a potential change. Events are debounced so
+// that the several events of a single swap result in one notification.
+//
+// Directories of config fragments that are created while Run is watching are
+// watched as well.
+//
+// The directory is also polled every pollInterval. This covers platforms or
+// mounts where inotify is unavailable, and config directories that do not
+// exist yet when the watcher starts.
+func (w *Watcher) Run(ctx context.Context) {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("configwatch: warning: failed to create file watcher, polling %q every %v instead: %v", w.path, w.pollInterval, err)
+	} else {
+		defer fsWatcher.Close()
+		dir := filepath.Dir(w.path)
+		if err := fsWatcher.Add(dir); err != nil {
+			log.Printf("configwatch: warning: failed to watch %q, polling %q every %v instead: %v", dir, w.path, w.pollInterval, err)
+		} else {
+			events = fsWatcher.Events
+			errs = fsWatcher.Errors
+		}
+		// A directory of config fragments changes inside, not in its parent.
+		for _, d := range configSubdirs(w.path) {
+			if err := fsWatcher.Add(d); err != nil {
+				log.Printf("configwatch: warning: failed to watch %q, relying on polling for it: %v", d, err)
+			}
+		}
+	}
+
+	pollTicker := time.NewTicker(w.pollInterval)
+	defer pollTicker.Stop()
+
+	// debounceTimer is only armed while a burst of events is in progress.
+	debounceTimer := time.NewTimer(w.debounce)
+	debounceTimer.Stop()
+	defer debounceTimer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				log.Printf("configwatch: warning: file watcher closed, polling %q every %v instead", w.path, w.pollInterval)
+				events, errs = nil, nil
+				continue
+			}
+			w.updateWatches(fsWatcher, ev)
+			debounceTimer.Reset(w.debounce)
+		case err, ok := <-errs:
+			if ok {
+				log.Printf("configwatch: error: file watcher error: %v", err)
+			}
+		case <-debounceTimer.C:
+			w.notify()
+		case <-pollTicker.C:
+			w.notify()
+		}
+	}
+}
+
+// updateWatches watches the config directories that ev created and stops
+// watching the ones it removed.
+func (w *Watcher) updateWatches(fsWatcher *fsnotify.Watcher, ev fsnotify.Event) {
+	rel, err := filepath.Rel(w.path, ev.Name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+	switch {
+	case ev.Has(fsnotify.Create):
+		if rel != "." && strings.HasPrefix(filepath.Base(ev.Name), ".") {
+			return
+		}
+		for _, d := range configSubdirs(ev.Name) {
+			if err := fsWatcher.Add(d); err != nil {
+				log.Printf("configwatch: warning: failed to watch %q, relying on polling for it: %v", d, err)
+			}
+		}
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		// The watch of a removed directory is gone already, this only fails
+		// if ev.Name was not watched in the first place.
+		fsWatcher.Remove(ev.Name)
+	}
+}
+
+// configSubdirs returns path and its subdirectories if path is a directory of
+// config fragments. Hidden directories are skipped like they are when reading
+// the fragments; changes to them show up as events in their parent anyway.
+func configSubdirs(path string) []string {
+	var dirs []string
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if p != path && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, p)
+		return nil
+	})
+	return dirs
+}
+
+// ReadRaw returns the raw content of the config file or directory at path,
+// for telling whether it changed. It returns an empty string if path does not
+// exist.
+func ReadRaw(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to stat file %q: %v", path, err)
+	}
+	if info.IsDir() {
+		return readRawDir(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %q: %v", path, err)
+	}
+
+	return string(data), nil
+}
+
+// readRawDir concatenates the config fragments in dir, including their names
+// so that renames are noticed as well.
+func readRawDir(dir string) (string, error) {
+	paths, err := confgenerator.ConfigFragments(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to list directory %q: %v", dir, err)
+	}
+
+	var raw strings.Builder
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %q: %v", path, err)
+		}
+		fmt.Fprintf(&raw, "# %s\n%s\n", path, data)
+	}
+	return raw.String(), nil
+}
+
+func (w *Watcher) notify() {
+	select {
+	case w.Changes <- struct{}{}:
+	default:
+		// A notification is already pending.
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configwatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeSecretVersion mimics how secret volumes are updated: the data is
+// written to a fresh directory and a "..data" symlink is swapped atomically.
+func writeSecretVersion(t *testing.T, dir, version, content string) {
+	t.Helper()
+	versionDir := filepath.Join(dir, version)
+	require.NoError(t, os.Mkdir(versionDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, "config.yaml"), []byte(content), 0644))
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	require.NoError(t, os.Symlink(version, tmpLink))
+	require.NoError(t, os.Rename(tmpLink, filepath.Join(dir, "..data")))
+}
+
+func TestWatcherFollowsSymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+	writeSecretVersion(t, dir, "..v1", "v1")
+	configFile := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.Symlink(filepath.Join("..data", "config.yaml"), configFile))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Use a poll interval that is long enough to not fire during the test.
+	w := New(configFile, 50*time.Millisecond, time.Hour)
+	go w.Run(ctx)
+	// Give the watcher a moment to register its watch.
+	time.Sleep(100 * time.Millisecond)
+
+	writeSecretVersion(t, dir, "..v2", "v2")
+	select {
+	case <-w.Changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change notification after symlink swap")
+	}
+
+	// A single swap produces several events which should be debounced into
+	// one notification.
+	select {
+	case <-w.Changes:
+		t.Fatal("unexpected second notification for a single swap")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatcherPollsMissingDirectory(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "missing", "config.yaml")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := New(configFile, time.Hour, 50*time.Millisecond)
+	go w.Run(ctx)
+
+	select {
+	case <-w.Changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification from polling fallback")
+	}
+}
+
+func TestWatcherWatchesFragmentDirectory(t *testing.T) {
+	configDir := filepath.Join(t.TempDir(), "config.d")
+	teamDir := filepath.Join(configDir, "team-a")
+	require.NoError(t, os.MkdirAll(teamDir, 0755))
+	writeSecretVersion(t, teamDir, "..v1", "v1")
+	require.NoError(t, os.Symlink(filepath.Join("..data", "config.yaml"), filepath.Join(teamDir, "config.yaml")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := New(configDir, 50*time.Millisecond, time.Hour)
+	go w.Run(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	writeSecretVersion(t, teamDir, "..v2", "v2")
+	select {
+	case <-w.Changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change notification for a fragment in a subdirectory")
+	}
+}
+
+func TestWatcherWatchesNewFragmentDirectory(t *testing.T) {
+	configDir := filepath.Join(t.TempDir(), "config.d")
+	require.NoError(t, os.Mkdir(configDir, 0755))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := New(configDir, 50*time.Millisecond, time.Hour)
+	go w.Run(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	teamDir := filepath.Join(configDir, "team-a")
+	require.NoError(t, os.Mkdir(teamDir, 0755))
+	select {
+	case <-w.Changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change notification for a new subdirectory")
+	}
+
+	// The change inside the new subdirectory must not wait for the poll.
+	require.NoError(t, os.WriteFile(filepath.Join(teamDir, "config.yaml"), []byte("v1"), 0644))
+	select {
+	case <-w.Changes:
+	case <-time.After(time.Second):
+		t.Fatal("no change notification for a fragment in a new subdirectory")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diagnostics writes the diagnostic dumps that the entrypoint and the
+// collector print to stdout on Signal, so that a snapshot of their state ends
+// up in the container logs.
+package diagnostics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Signal asks a process for a diagnostic dump.
+const Signal = syscall.SIGUSR1
+
+// Section is a part of a diagnostic dump.
+type Section struct {
+	Title string
+	Write func(w io.Writer)
+}
+
+// Write writes a diagnostic dump of process to w: the given sections,
+// followed by the memory statistics and the goroutine stacks of the process.
+// The dump is written at once, so that it is not interleaved with the output
+// of other goroutines.
+func Write(w io.Writer, process string, sections ...Section) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "=== diagnostics of %s (pid %d) at %s ===\n", process, os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+	sections = append(sections,
+		Section{Title: "memory", Write: writeMemStats},
+		Section{Title: "goroutines", Write: writeGoroutines},
+	)
+	for _, s := range sections {
+		fmt.Fprintf(&buf, "--- %s ---\n", s.Title)
+		s.Write(&buf)
+	}
+	fmt.Fprintf(&buf, "=== end of diagnostics of %s ===\n", process)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Whether Signal is already handled in this process.
+var (
+	handledMu sync.Mutex
+	handled   bool
+)
+
+// claim reports whether the caller is the first to handle Signal in this
+// process.
+func claim() bool {
+	handledMu.Lock()
+	defer handledMu.Unlock()
+	if handled {
+		return false
+	}
+	handled = true
+	return true
+}
+
+// Notify relays Signal to c, for a process that writes its dumps itself.
+// Later calls of DumpOnSignal in the same process have no effect, so that a
+// process never dumps twice on the same signal.
+func Notify(c chan<- os.Signal) {
+	claim()
+	signal.Notify(c, Signal)
+}
+
+// DumpOnSignal writes a dump of process to stdout whenever it receives
+// Signal. Without it, Signal terminates a Go program. Only the first call in
+// a process has an effect, and none after Notify.
+func DumpOnSignal(process string, sections ...Section) {
+	if !claim() {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, Signal)
+	go func() {
+		for range signals {
+			Write(os.Stdout, process, sections...)
+		}
+	}()
+}
+
+func writeMemStats(w io.Writer) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	fmt.Fprintf(w, "heap_alloc_bytes: %d\n", m.HeapAlloc)
+	fmt.Fprintf(w, "heap_sys_bytes: %d\n", m.HeapSys)
+	fmt.Fprintf(w, "sys_bytes: %d\n", m.Sys)
+	fmt.Fprintf(w, "num_gc: %d\n", m.NumGC)
+	fmt.Fprintf(w, "goroutines: %d\n", runtime.NumGoroutine())
+}
+
+func writeGoroutines(w io.Writer) {
+	// Debug level 2 prints the stacks in the format of an unrecovered panic,
+	// which is the most familiar one.
+	if err := pprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		fmt.Fprintf(w, "failed to write goroutine stacks: %v\n", err)
+	}
+}
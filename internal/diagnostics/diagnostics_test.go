@@ -0,0 +1,67 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "test", Section{
+		Title: "custom",
+		Write: func(w io.Writer) { fmt.Fprintln(w, "custom content") },
+	}))
+
+	dump := buf.String()
+	assert.True(t, strings.HasPrefix(dump, fmt.Sprintf("=== diagnostics of test (pid %d) at ", os.Getpid())), dump)
+	assert.True(t, strings.HasSuffix(dump, "=== end of diagnostics of test ===\n"), dump)
+
+	// Custom sections come before the runtime ones.
+	custom := strings.Index(dump, "--- custom ---\ncustom content\n")
+	memory := strings.Index(dump, "--- memory ---\nheap_alloc_bytes: ")
+	goroutines := strings.Index(dump, "--- goroutines ---\ngoroutine ")
+	require.NotEqual(t, -1, custom, dump)
+	require.NotEqual(t, -1, memory, dump)
+	require.NotEqual(t, -1, goroutines, dump)
+	assert.Less(t, custom, memory)
+	assert.Less(t, memory, goroutines)
+	assert.Contains(t, dump, "diagnostics.TestWrite")
+}
+
+func TestNotifyClaimsSignal(t *testing.T) {
+	c := make(chan os.Signal, 1)
+	Notify(c)
+	defer signal.Stop(c)
+	assert.False(t, claim(), "DumpOnSignal must not install a second handler after Notify")
+
+	require.NoError(t, syscall.Kill(os.Getpid(), Signal))
+	select {
+	case <-c:
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal was not relayed")
+	}
+}
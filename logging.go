@@ -0,0 +1,63 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/internal/cloudlogging"
+)
+
+// Format of the entrypoint and collector logs. The flag is overridden by
+// RUN_GMP_LOG_FORMAT, which is also the variable the collector reads it from.
+var logFormat = cloudlogging.FormatText
+
+// setupLogging applies logFormat to the entrypoint's logs and passes it on to
+// the collector, which inherits the entrypoint's environment.
+func setupLogging() error {
+	switch logFormat {
+	case cloudlogging.FormatText:
+	case cloudlogging.FormatJSON:
+		// The standard library's logger is used throughout the entrypoint
+		// and confgenerator, so redirect it rather than replacing it. The
+		// severity of each message is given by its cloudlogging marker.
+		cloudlogging.RedirectStdLog(cloudlogging.NewLogger("entrypoint"))
+	default:
+		return fmt.Errorf("invalid log format %q, must be %q or %q", logFormat, cloudlogging.FormatText, cloudlogging.FormatJSON)
+	}
+	return os.Setenv(cloudlogging.FormatEnv, logFormat)
+}
+
+// collectorLogging returns the stderr of the collector subprocess and the
+// flags that make it log in logFormat. The collector built from the
+// distribution manifest has no Cloud Logging encoder, so for the JSON format
+// it logs zap's JSON to a pipe, which is converted in the background.
+func collectorLogging() (*os.File, []string, error) {
+	if logFormat != cloudlogging.FormatJSON {
+		return os.Stderr, nil, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create pipe for collector logs: %v", err)
+	}
+	go func() {
+		if err := cloudlogging.CopyZapJSON(os.Stderr, r, cloudlogging.Labels("collector")); err != nil {
+			log.Printf("entrypoint: error: failed to copy collector logs: %v", err)
+		}
+	}()
+	return w, []string{cloudlogging.ZapJSONFlag}, nil
+}
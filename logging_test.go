@@ -0,0 +1,50 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/internal/cloudlogging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupLoggingRejectsUnknownFormat(t *testing.T) {
+	prev := logFormat
+	t.Cleanup(func() { logFormat = prev })
+
+	logFormat = "xml"
+	assert.ErrorContains(t, setupLogging(), `invalid log format "xml"`)
+}
+
+func TestCollectorLogging(t *testing.T) {
+	prev := logFormat
+	t.Cleanup(func() { logFormat = prev })
+
+	logFormat = cloudlogging.FormatText
+	stderr, flags, err := collectorLogging()
+	require.NoError(t, err)
+	assert.Equal(t, os.Stderr, stderr)
+	assert.Empty(t, flags)
+
+	logFormat = cloudlogging.FormatJSON
+	stderr, flags, err = collectorLogging()
+	require.NoError(t, err)
+	t.Cleanup(func() { stderr.Close() })
+	assert.NotEqual(t, os.Stderr, stderr)
+	assert.Equal(t, []string{cloudlogging.ZapJSONFlag}, flags)
+}
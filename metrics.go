@@ -0,0 +1,52 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The entrypoint's own metrics. They are served on the entrypoint mux and
+// scraped by the collector's self metrics pipeline, so every name here must
+// also be allowed by the filter in confgenerator.AgentSelfMetrics and renamed
+// to agent/<name> there.
+var (
+	entrypointRegistry = prometheus.NewRegistry()
+
+	configReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_config_reload_count",
+		Help: "Number of RunMonitoring config reloads attempted by the sidecar.",
+	}, []string{"status"})
+)
+
+const (
+	reloadSuccess = "success"
+	reloadFailure = "failure"
+)
+
+func init() {
+	entrypointRegistry.MustRegister(configReloads)
+	// Export both series from the start so that failures show up as an
+	// increase rather than as a new time series.
+	configReloads.WithLabelValues(reloadSuccess)
+	configReloads.WithLabelValues(reloadFailure)
+}
+
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(entrypointRegistry, promhttp.HandlerOpts{})
+}
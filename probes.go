@@ -0,0 +1,93 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// Reported by the collector's Prometheus receiver for every scrape target:
+	// 1 if the last scrape succeeded, 0 if it failed and -1 if the target has
+	// not been scraped yet. Kept in sync with prometheusreceiver.TargetHealthMetric.
+	targetHealthMetric = "otelcol_receiver_prometheus_target_health"
+	// The receiver that scrapes the endpoints of the RunMonitoring config.
+	applicationMetricsReceiver = "prometheus/application-metrics"
+)
+
+// Number of targets the RunMonitoring config the collector is running with is
+// certain to produce, see RunMonitoringConfig.ExpectedTargets. Readiness waits
+// for at least this many targets.
+var expectedTargets atomic.Int64
+
+// startupHandler succeeds once the collector process is running and its self
+// metrics port answers.
+func startupHandler(w http.ResponseWriter, r *http.Request) {
+	if !collector.Running() {
+		http.Error(w, "collector is not running", http.StatusServiceUnavailable)
+		return
+	}
+	if _, err := scrapeSelfMetrics(r.Context(), selfMetricsClient, selfMetricsURL()); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "collector started")
+}
+
+// readyHandler succeeds once the first scrape of every RunMonitoring endpoint
+// has been attempted, whether or not it succeeded.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	families, err := scrapeSelfMetrics(r.Context(), selfMetricsClient, selfMetricsURL())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err := checkTargetsScraped(families[targetHealthMetric], int(expectedTargets.Load())); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "all targets scraped")
+}
+
+// checkTargetsScraped returns an error unless at least expected application
+// targets are known and all of them have been scraped at least once.
+func checkTargetsScraped(health *dto.MetricFamily, expected int) error {
+	var scraped int
+	var pending []string
+	for _, m := range health.GetMetric() {
+		if labelValue(m, "receiver") != applicationMetricsReceiver {
+			continue
+		}
+		if m.GetGauge().GetValue() < 0 {
+			pending = append(pending, fmt.Sprintf("%s (%s)", labelValue(m, "target"), labelValue(m, "job")))
+			continue
+		}
+		scraped++
+	}
+	if len(pending) > 0 {
+		sort.Strings(pending)
+		return fmt.Errorf("waiting for the first scrape of %s", strings.Join(pending, ", "))
+	}
+	if scraped < expected {
+		return fmt.Errorf("waiting for scrape targets, %d of %d discovered", scraped, expected)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTargetsScraped(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		metrics  string
+		expected int
+		wantErr  string
+	}{
+		{
+			name: "all scraped",
+			metrics: `otelcol_receiver_prometheus_target_health{receiver="prometheus/application-metrics",job="run-gmp-sidecar-0",target="http://0.0.0.0:8080/metrics"} 1
+otelcol_receiver_prometheus_target_health{receiver="prometheus/application-metrics",job="run-gmp-sidecar-1",target="http://0.0.0.0:8081/metrics"} 0
+`,
+			expected: 2,
+		},
+		{
+			name: "first scrape pending",
+			metrics: `otelcol_receiver_prometheus_target_health{receiver="prometheus/application-metrics",job="run-gmp-sidecar-0",target="http://0.0.0.0:8080/metrics"} 1
+otelcol_receiver_prometheus_target_health{receiver="prometheus/application-metrics",job="run-gmp-sidecar-1",target="http://0.0.0.0:8081/metrics"} -1
+`,
+			expected: 2,
+			wantErr:  "waiting for the first scrape of http://0.0.0.0:8081/metrics (run-gmp-sidecar-1)",
+		},
+		{
+			name: "targets not discovered yet",
+			metrics: `otelcol_receiver_prometheus_target_health{receiver="prometheus/run-gmp-self-metrics",job="run-gmp-sidecar-self-metrics",target="http://0.0.0.0:42/metrics"} 1
+`,
+			expected: 1,
+			wantErr:  "waiting for scrape targets, 0 of 1 discovered",
+		},
+		{
+			name: "self metrics targets are ignored",
+			metrics: `otelcol_receiver_prometheus_target_health{receiver="prometheus/run-gmp-self-metrics",job="run-gmp-sidecar-self-metrics",target="http://0.0.0.0:42/metrics"} -1
+`,
+			expected: 0,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var parser expfmt.TextParser
+			families, err := parser.TextToMetricFamilies(strings.NewReader("# TYPE otelcol_receiver_prometheus_target_health gauge\n" + tc.metrics))
+			require.NoError(t, err)
+
+			err = checkTargetsScraped(families[targetHealthMetric], tc.expected)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckTargetsScrapedWithoutHealthMetric(t *testing.T) {
+	assert.NoError(t, checkTargetsScraped(nil, 0))
+	assert.Error(t, checkTargetsScraped(nil, 1))
+}
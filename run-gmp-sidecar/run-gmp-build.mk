@@ -3,7 +3,7 @@
 include ./Makefile
 
 ENTRYPOINT_BINARY_NAME = run-gmp-entrypoint
-ENTRYPOINT_SRC = ./entrypoint.go
+ENTRYPOINT_SRC = .
 ENTRYPOINT_IMPORT_PATH = github.com/GoogleCloudPlatform/run-gmp-sidecar/confgenerator
 ENTRYPOINT_LD_FLAGS := -X $(ENTRYPOINT_IMPORT_PATH).Version=$(VERSION)
 
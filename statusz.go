@@ -0,0 +1,215 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/confgenerator"
+	yaml "github.com/goccy/go-yaml"
+)
+
+// HTTP path of the debug page that shows what the sidecar is running.
+const statuszPath = "/statusz"
+
+// statusTracker keeps the state shown on /statusz that is not available
+// elsewhere: the config in use and the outcome of the last reload.
+type statusTracker struct {
+	mu            sync.Mutex
+	source        string
+	config        *confgenerator.RunMonitoringConfig
+	otel          string
+	lastReload    time.Time
+	lastReloadErr error
+}
+
+var sidecarStatus = &statusTracker{}
+
+// SetConfig records the config the collector was started with, and the OTel
+// config generated from it.
+func (s *statusTracker) SetConfig(source configSource, c *confgenerator.RunMonitoringConfig, otel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.source = source.String()
+	s.config = c
+	s.otel = otel
+}
+
+// RecordReload records the outcome of a reload. The configs are only recorded
+// if the reload succeeded, since the collector keeps running with the
+// previous ones otherwise.
+func (s *statusTracker) RecordReload(c *confgenerator.RunMonitoringConfig, otel string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastReload = time.Now()
+	s.lastReloadErr = err
+	if err == nil {
+		s.config = c
+		s.otel = otel
+	}
+}
+
+// statusPage is the content of /statusz. It is served as is for JSON
+// requests and rendered with statuszTemplate otherwise.
+type statusPage struct {
+	ConfigSource     string            `json:"configSource"`
+	Config           string            `json:"config"`
+	OtelConfigFile   string            `json:"otelConfigFile,omitempty"`
+	OtelConfig       string            `json:"otelConfig"`
+	SelfMetricsPort  int               `json:"selfMetricsPort"`
+	Collector        collectorStatus   `json:"collector"`
+	LastReload       *reloadStatus     `json:"lastReload,omitempty"`
+	CloudRunMetadata map[string]string `json:"cloudRunMetadata"`
+}
+
+type collectorStatus struct {
+	InProcess bool       `json:"inProcess"`
+	Running   bool       `json:"running"`
+	Healthy   bool       `json:"healthy"`
+	PID       int        `json:"pid,omitempty"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	Uptime    string     `json:"uptime,omitempty"`
+}
+
+type reloadStatus struct {
+	Time      time.Time `json:"time"`
+	Succeeded bool      `json:"succeeded"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Page returns the current content of /statusz.
+func (s *statusTracker) Page() statusPage {
+	s.mu.Lock()
+	page := statusPage{
+		ConfigSource:     s.source,
+		OtelConfig:       s.otel,
+		SelfMetricsPort:  selfMetricsPort,
+		Collector:        collectorStatus{InProcess: inProcess},
+		CloudRunMetadata: map[string]string{},
+	}
+	// The in-process collector gets its config in memory.
+	if !inProcess {
+		page.OtelConfigFile = otelConfigFile
+	}
+	c := s.config
+	if !s.lastReload.IsZero() {
+		page.LastReload = &reloadStatus{Time: s.lastReload, Succeeded: s.lastReloadErr == nil}
+		if s.lastReloadErr != nil {
+			page.LastReload.Error = s.lastReloadErr.Error()
+		}
+	}
+	s.mu.Unlock()
+
+	if c != nil {
+		data, err := yaml.Marshal(c)
+		if err != nil {
+			page.Config = err.Error()
+		} else {
+			page.Config = string(data)
+		}
+		if c.Env != nil && c.Env.IsJob() {
+			page.CloudRunMetadata["CLOUD_RUN_JOB"] = c.Env.Job
+			page.CloudRunMetadata["CLOUD_RUN_EXECUTION"] = c.Env.Execution
+			page.CloudRunMetadata["CLOUD_RUN_TASK_INDEX"] = c.Env.TaskIndex
+			page.CloudRunMetadata["CLOUD_RUN_TASK_COUNT"] = c.Env.TaskCount
+		} else if c.Env != nil {
+			page.CloudRunMetadata["K_SERVICE"] = c.Env.Service
+			page.CloudRunMetadata["K_REVISION"] = c.Env.Revision
+			page.CloudRunMetadata["K_CONFIGURATION"] = c.Env.Configuration
+		}
+	}
+
+	if collector != nil {
+		page.Collector.Healthy = collector.Healthy()
+		if pid, startedAt := collector.Process(); pid != 0 {
+			page.Collector.Running = true
+			page.Collector.PID = pid
+			page.Collector.StartedAt = &startedAt
+			page.Collector.Uptime = time.Since(startedAt).Round(time.Second).String()
+		}
+	}
+	return page
+}
+
+var statuszTemplate = template.Must(template.New("statusz").Parse(`<!DOCTYPE html>
+<html>
+<head><title>run-gmp-sidecar status</title></head>
+<body>
+<h1>run-gmp-sidecar status</h1>
+<h2>Collector</h2>
+<table>
+{{- with .Collector}}
+<tr><th align="left">In process</th><td>{{.InProcess}}</td></tr>
+<tr><th align="left">Running</th><td>{{.Running}}</td></tr>
+<tr><th align="left">Healthy</th><td>{{.Healthy}}</td></tr>
+{{- if .Running}}
+<tr><th align="left">PID</th><td>{{.PID}}</td></tr>
+<tr><th align="left">Started</th><td>{{.StartedAt}}</td></tr>
+<tr><th align="left">Uptime</th><td>{{.Uptime}}</td></tr>
+{{- end}}
+{{- end}}
+<tr><th align="left">Self metrics port</th><td>{{.SelfMetricsPort}}</td></tr>
+</table>
+<h2>Last reload</h2>
+{{- with .LastReload}}
+<table>
+<tr><th align="left">Time</th><td>{{.Time}}</td></tr>
+<tr><th align="left">Outcome</th><td>{{if .Succeeded}}succeeded{{else}}failed: {{.Error}}{{end}}</td></tr>
+</table>
+{{- else}}
+<p>The config has not been reloaded since the sidecar started.</p>
+{{- end}}
+<h2>Cloud Run metadata</h2>
+<table>
+{{- range $name, $value := .CloudRunMetadata}}
+<tr><th align="left">{{$name}}</th><td>{{$value}}</td></tr>
+{{- end}}
+</table>
+<h2>RunMonitoring config</h2>
+<p>Read from {{.ConfigSource}}</p>
+<pre>{{.Config}}</pre>
+<h2>Generated OTel config</h2>
+{{- with .OtelConfigFile}}
+<p>Written to {{.}}</p>
+{{- end}}
+<pre>{{.OtelConfig}}</pre>
+</body>
+</html>
+`))
+
+// statuszHandler serves the debug page as HTML, or as JSON if requested with
+// ?format=json or an Accept header of application/json.
+func statuszHandler(w http.ResponseWriter, r *http.Request) {
+	page := sidecarStatus.Page()
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(page); err != nil {
+			log.Printf("entrypoint: error: failed to write %s: %v", statuszPath, err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statuszTemplate.Execute(w, page); err != nil {
+		log.Printf("entrypoint: error: failed to write %s: %v", statuszPath, err)
+	}
+}
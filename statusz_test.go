@@ -0,0 +1,103 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/run-gmp-sidecar/confgenerator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStatus(t *testing.T) {
+	t.Helper()
+	restoreSettings(t)
+	prev := sidecarStatus
+	t.Cleanup(func() { sidecarStatus = prev })
+	sidecarStatus = &statusTracker{}
+
+	otelConfigFile = "/run/rungmp/otel.yaml"
+	sidecarStatus.SetConfig(fileConfigSource("/etc/rungmp/config.yaml"), &confgenerator.RunMonitoringConfig{
+		Env: &confgenerator.CloudRunEnvironment{Service: "my-service", Revision: "my-service-00001"},
+	}, "receivers: {}\n")
+}
+
+func getStatusz(t *testing.T, header http.Header, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, statuszPath+query, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	statuszHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec
+}
+
+func TestStatuszJSON(t *testing.T) {
+	newTestStatus(t)
+	sidecarStatus.RecordReload(nil, "", errors.New("invalid config"))
+
+	for _, rec := range []*httptest.ResponseRecorder{
+		getStatusz(t, nil, "?format=json"),
+		getStatusz(t, http.Header{"Accept": {"application/json"}}, ""),
+	} {
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var page statusPage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		assert.Equal(t, "/etc/rungmp/config.yaml", page.ConfigSource)
+		assert.Equal(t, "receivers: {}\n", page.OtelConfig)
+		assert.Equal(t, "/run/rungmp/otel.yaml", page.OtelConfigFile)
+		assert.Equal(t, "my-service", page.CloudRunMetadata["K_SERVICE"])
+		assert.Equal(t, "my-service-00001", page.CloudRunMetadata["K_REVISION"])
+		require.NotNil(t, page.LastReload)
+		assert.False(t, page.LastReload.Succeeded)
+		assert.Equal(t, "invalid config", page.LastReload.Error)
+	}
+}
+
+func TestStatuszHTML(t *testing.T) {
+	newTestStatus(t)
+
+	rec := getStatusz(t, nil, "")
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, "Read from /etc/rungmp/config.yaml")
+	assert.Contains(t, body, "<pre>receivers: {}\n</pre>")
+	assert.Contains(t, body, "<tr><th align=\"left\">K_SERVICE</th><td>my-service</td></tr>")
+	assert.Contains(t, body, "The config has not been reloaded since the sidecar started.")
+}
+
+func TestStatusTrackerKeepsLastGoodConfig(t *testing.T) {
+	s := &statusTracker{}
+	good := &confgenerator.RunMonitoringConfig{}
+	s.SetConfig(inlineConfigSource(""), good, "good")
+
+	s.RecordReload(nil, "", errors.New("failed to signal the collector"))
+	assert.Same(t, good, s.config)
+	assert.Equal(t, "good", s.otel)
+
+	next := &confgenerator.RunMonitoringConfig{}
+	s.RecordReload(next, "next", nil)
+	assert.Same(t, next, s.config)
+	assert.Equal(t, "next", s.otel)
+	assert.NoError(t, s.lastReloadErr)
+}
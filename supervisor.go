@@ -0,0 +1,258 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// collectorSupervisor runs the collector as a child process and restarts it
+// with exponential backoff whenever it exits without being asked to.
+type collectorSupervisor struct {
+	argv []string
+	// Where the collector's stderr goes, os.Stderr unless the logs need to
+	// be converted.
+	stderr *os.File
+
+	// Backoff before restarting the collector. It starts at minBackoff and
+	// doubles on every consecutive crash, up to maxBackoff.
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	// A collector that stays up for at least stableAfter is considered
+	// healthy again, which resets the backoff and the crash count.
+	stableAfter time.Duration
+	// After crashLoopThreshold consecutive crashes the collector is
+	// considered to be crash looping and the sidecar reports itself unhealthy.
+	crashLoopThreshold int
+
+	mu        sync.Mutex
+	process   *os.Process
+	startedAt time.Time
+	crashes   int
+	stopping  bool
+	lastState *os.ProcessState
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newCollectorSupervisor(argv ...string) *collectorSupervisor {
+	return &collectorSupervisor{
+		argv:               argv,
+		stderr:             os.Stderr,
+		minBackoff:         1 * time.Second,
+		maxBackoff:         30 * time.Second,
+		stableAfter:        1 * time.Minute,
+		crashLoopThreshold: 5,
+		stop:               make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+}
+
+// Start starts the collector and keeps supervising it in the background. Only
+// the first start is reported as an error; later restarts are retried.
+func (s *collectorSupervisor) Start() error {
+	if err := s.startProcess(); err != nil {
+		return err
+	}
+	go s.supervise()
+	return nil
+}
+
+// errStopping is returned when the collector is not started because the
+// supervisor is shutting down.
+var errStopping = errors.New("collector supervisor is stopping")
+
+func (s *collectorSupervisor) startProcess() error {
+	// Hold the lock while starting so that Stop either sees the new process
+	// or prevents it from being started.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping {
+		return errStopping
+	}
+
+	var procAttr os.ProcAttr
+	procAttr.Files = []*os.File{nil, /* stdin is not needed for the collector */
+		os.Stdout, s.stderr}
+	process, err := os.StartProcess(s.argv[0], s.argv, &procAttr)
+	if err != nil {
+		return err
+	}
+	s.process = process
+	s.startedAt = time.Now()
+	return nil
+}
+
+func (s *collectorSupervisor) supervise() {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		process := s.process
+		s.mu.Unlock()
+
+		if process != nil {
+			state, err := process.Wait()
+			if err != nil {
+				log.Printf("entrypoint: error: failed to wait for collector (pid %d): %v", process.Pid, err)
+			}
+
+			s.mu.Lock()
+			s.process = nil
+			s.lastState = state
+			if s.stopping {
+				s.mu.Unlock()
+				log.Printf("entrypoint: collector (pid %d) %s", process.Pid, describeExit(state))
+				return
+			}
+			if time.Since(s.startedAt) >= s.stableAfter {
+				s.crashes = 0
+			}
+			s.mu.Unlock()
+			log.Printf("entrypoint: error: collector (pid %d) %s unexpectedly", process.Pid, describeExit(state))
+		}
+
+		s.mu.Lock()
+		s.crashes++
+		crashes := s.crashes
+		s.mu.Unlock()
+
+		if crashes == s.crashLoopThreshold {
+			log.Printf("entrypoint: error: collector crashed %d times in a row, reporting unhealthy", crashes)
+		}
+		backoff := s.backoff(crashes)
+		log.Printf("entrypoint: restarting collector in %v", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			return
+		}
+
+		if err := s.startProcess(); err == errStopping {
+			return
+		} else if err != nil {
+			log.Printf("entrypoint: error: failed to restart collector: %v", err)
+			continue
+		}
+		log.Printf("entrypoint: restarted collector")
+	}
+}
+
+// backoff returns how long to wait before the restart following the given
+// number of consecutive crashes.
+func (s *collectorSupervisor) backoff(crashes int) time.Duration {
+	backoff := s.minBackoff
+	for i := 1; i < crashes && backoff < s.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > s.maxBackoff {
+		backoff = s.maxBackoff
+	}
+	return backoff
+}
+
+// Healthy reports whether the collector is not crash looping.
+func (s *collectorSupervisor) Healthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.process != nil && time.Since(s.startedAt) >= s.stableAfter {
+		return true
+	}
+	return s.crashes < s.crashLoopThreshold
+}
+
+// Running reports whether the collector process is currently running.
+func (s *collectorSupervisor) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.process != nil
+}
+
+// Process returns the PID of the running collector and when it was started.
+// The PID is 0 if the collector is not running.
+func (s *collectorSupervisor) Process() (pid int, startedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.process == nil {
+		return 0, time.Time{}
+	}
+	return s.process.Pid, s.startedAt
+}
+
+// Signal sends sig to the running collector. It is a no-op while the
+// collector is waiting to be restarted, since a restarted collector picks up
+// the current config anyway.
+func (s *collectorSupervisor) Signal(sig os.Signal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.process == nil {
+		return nil
+	}
+	return s.process.Signal(sig)
+}
+
+// Stop forwards sig to the collector, stops restarting it and waits for it to
+// exit. A collector that is still running after timeout is killed. It returns
+// the final state of the collector process, which is nil if the collector was
+// not running.
+func (s *collectorSupervisor) Stop(sig os.Signal, timeout time.Duration) *os.ProcessState {
+	s.mu.Lock()
+	s.stopping = true
+	process := s.process
+	if process != nil {
+		if err := process.Signal(sig); err != nil {
+			log.Printf("entrypoint: error: failed to signal collector: %v", err)
+		}
+	}
+	s.mu.Unlock()
+
+	close(s.stop)
+	select {
+	case <-s.done:
+	case <-time.After(timeout):
+		log.Printf("entrypoint: warning: collector did not exit within %v, killing it", timeout)
+		if process != nil {
+			if err := process.Kill(); err != nil {
+				log.Printf("entrypoint: error: failed to kill collector: %v", err)
+			}
+		}
+		<-s.done
+	}
+
+	if process == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastState
+}
+
+// describeExit returns a human readable description of how a process exited.
+func describeExit(state *os.ProcessState) string {
+	if state == nil {
+		return "exited"
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return fmt.Sprintf("was killed by signal %v", status.Signal())
+	}
+	return fmt.Sprintf("exited with code %d", state.ExitCode())
+}
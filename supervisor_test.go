@@ -0,0 +1,89 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lookPath(t *testing.T, name string) string {
+	t.Helper()
+	path, err := exec.LookPath(name)
+	if err != nil {
+		t.Skipf("%s not available: %v", name, err)
+	}
+	return path
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	s := newCollectorSupervisor("unused")
+	s.minBackoff = time.Second
+	s.maxBackoff = 10 * time.Second
+
+	assert.Equal(t, 1*time.Second, s.backoff(1))
+	assert.Equal(t, 2*time.Second, s.backoff(2))
+	assert.Equal(t, 4*time.Second, s.backoff(3))
+	assert.Equal(t, 8*time.Second, s.backoff(4))
+	assert.Equal(t, 10*time.Second, s.backoff(5))
+	assert.Equal(t, 10*time.Second, s.backoff(50))
+}
+
+func TestSupervisorDetectsCrashLoop(t *testing.T) {
+	s := newCollectorSupervisor(lookPath(t, "false"))
+	s.minBackoff = time.Millisecond
+	s.maxBackoff = time.Millisecond
+	s.crashLoopThreshold = 3
+
+	require.True(t, s.Healthy())
+	require.NoError(t, s.Start())
+	defer s.Stop(syscall.SIGTERM, time.Minute)
+
+	require.Eventually(t, func() bool { return !s.Healthy() }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSupervisorStopForwardsSignal(t *testing.T) {
+	s := newCollectorSupervisor(lookPath(t, "sleep"), "60")
+	require.NoError(t, s.Start())
+
+	state := s.Stop(syscall.SIGTERM, time.Minute)
+	require.NotNil(t, state)
+	status, ok := state.Sys().(syscall.WaitStatus)
+	require.True(t, ok)
+	assert.True(t, status.Signaled())
+	assert.Equal(t, syscall.SIGTERM, status.Signal())
+	assert.True(t, s.Healthy())
+}
+
+func TestSupervisorStopKillsAfterTimeout(t *testing.T) {
+	// The shell ignores SIGTERM, so only SIGKILL can stop it.
+	s := newCollectorSupervisor(lookPath(t, "sh"), "-c", "trap '' TERM; while :; do sleep 0.01; done")
+	require.NoError(t, s.Start())
+	// Give the shell a moment to install its trap.
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	state := s.Stop(syscall.SIGTERM, 100*time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	require.NotNil(t, state)
+	status, ok := state.Sys().(syscall.WaitStatus)
+	require.True(t, ok)
+	assert.Equal(t, syscall.SIGKILL, status.Signal())
+}
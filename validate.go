@@ -0,0 +1,78 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// validateCommand is the subcommand that checks RunMonitoring files offline,
+// e.g. before they are pushed as a new secret version:
+//
+//	run-gmp-entrypoint validate [FILE...]
+//
+// It validates the files given as arguments, or the configured config source
+// if there are none.
+const validateCommand = "validate"
+
+// validateConfigs runs the full translation of the RunMonitoring config of
+// every source and reports the outcome to out, along with the deprecation
+// warnings of valid configs. It returns false if any of the configs is
+// invalid.
+func validateConfigs(ctx context.Context, sources []configSource, out io.Writer) bool {
+	valid := true
+	for _, source := range sources {
+		warnings, err := validateConfig(ctx, source)
+		if err != nil {
+			fmt.Fprintf(out, "%s: %v\n", source, err)
+			valid = false
+			continue
+		}
+		fmt.Fprintf(out, "%s: OK\n", source)
+		for _, w := range warnings {
+			fmt.Fprintf(out, "%s: warning: %s\n", source, w)
+		}
+	}
+	return valid
+}
+
+// validateConfig returns the deprecation warnings of the config of source, or
+// an error if it is invalid.
+func validateConfig(ctx context.Context, source configSource) ([]string, error) {
+	// ReadConfigFromFile falls back to the default config for missing files,
+	// which is not what someone validating a file wants.
+	if path, ok := source.(fileConfigSource); ok {
+		if _, err := os.Stat(string(path)); err != nil {
+			return nil, err
+		}
+	}
+	raw, err := source.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := source.Parse(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	// The ports only end up in the generated config, they don't need to be
+	// free here.
+	if _, err := c.GenerateOtelConfig(ctx, 1, livenessProbePort); err != nil {
+		return nil, err
+	}
+	return c.Warnings, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfigs(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+
+	var out strings.Builder
+	valid := validateConfigs(context.Background(), []configSource{
+		fileConfigSource("default-config.yaml"),
+		fileConfigSource(missing),
+	}, &out)
+
+	assert.False(t, valid)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "default-config.yaml: OK", lines[0])
+	assert.Contains(t, lines[1], missing+": ")
+	assert.Contains(t, lines[1], "no such file or directory")
+}
+
+func TestValidateConfigsReportsWarnings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`apiVersion: monitoring.googleapis.com/v1beta
+kind: RunMonitoring
+metadata:
+  name: run-run-run
+spec:
+  endpoints:
+  - port: 8080
+    relabeling:
+    - action: replace
+      sourceLabels:
+      - __scheme__
+      targetLabel: scheme
+`), 0644))
+
+	var out strings.Builder
+	assert.True(t, validateConfigs(context.Background(), []configSource{fileConfigSource(path)}, &out))
+	assert.Equal(t, path+": OK\n"+
+		path+": warning: [1:13] apiVersion: monitoring.googleapis.com/v1beta is deprecated, use monitoring.googleapis.com/v1\n",
+		out.String())
+}